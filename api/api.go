@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fd0/nepomuk/database"
+	"github.com/fd0/nepomuk/queue"
+	"github.com/sirupsen/logrus"
+)
+
+// Server provides an HTTP API for the archive.
+type Server struct {
+	Database *database.Database
+	Queue    *queue.Queue
+
+	// Layout is the configured layout the archive is migrated to.
+	Layout *database.Layout
+
+	// OnMigrate is called after the archive was migrated to a new layout.
+	OnMigrate func()
+
+	// OnPurge is called after files were purged from the trash.
+	OnPurge func()
+
+	// OnSettings is called after settings stored in the database were
+	// changed, e.g. the cadence of a correspondent.
+	OnSettings func()
+
+	// Unknown is the correspondent of files which were not recognized, it is
+	// ignored when looking for recurring documents.
+	Unknown string
+
+	log logrus.FieldLogger
+}
+
+// SetLogger updates the logger to use.
+func (s *Server) SetLogger(logger logrus.FieldLogger) {
+	s.log = logger.WithField("component", "api")
+}
+
+// Handler returns an http.Handler for the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("GET /api/files", s.handleFiles)
+	mux.HandleFunc("GET /api/files/{id}", s.handleFile)
+	mux.HandleFunc("PATCH /api/files/{id}", s.handleUpdateFile)
+	mux.HandleFunc("GET /api/tags", s.handleTags)
+	mux.HandleFunc("GET /api/files/{id}/history", s.handleHistory)
+	mux.HandleFunc("POST /api/files/{id}/revert", s.handleRevert)
+	mux.HandleFunc("DELETE /api/files/{id}", s.handleDeleteFile)
+	mux.HandleFunc("GET /api/trash", s.handleTrash)
+	mux.HandleFunc("POST /api/trash/{id}/restore", s.handleRestore)
+	mux.HandleFunc("DELETE /api/trash/{id}", s.handlePurge)
+	mux.HandleFunc("DELETE /api/trash", s.handlePurge)
+	mux.HandleFunc("POST /api/migrate", s.handleMigrate)
+	mux.HandleFunc("GET /api/recurring", s.handleRecurring)
+	mux.HandleFunc("PUT /api/recurring/{correspondent}", s.handleSetCadence)
+
+	return mux
+}
+
+// Headers sent by the command line client.
+const (
+	HeaderSource = "X-Nepomuk-Source"
+	HeaderUser   = "X-Nepomuk-User"
+)
+
+// origin returns who sent the request, for the history. The API does not
+// authenticate users, so the user name sent by the client is recorded as
+// client-reported.
+func origin(req *http.Request) database.Origin {
+	o := database.Origin{
+		Source:       database.SourceAPI,
+		ReportedUser: req.Header.Get(HeaderUser),
+	}
+
+	if req.Header.Get(HeaderSource) == database.SourceCLI {
+		o.Source = database.SourceCLI
+	}
+
+	return o
+}
+
+// writeJSON sends data as JSON to the client.
+func (s *Server) writeJSON(res http.ResponseWriter, data any) {
+	res.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(data)
+	if err != nil {
+		s.log.Warnf("send response: %v", err)
+	}
+}
+
+// StageStatus describes the queue and workers of a pipeline stage.
+type StageStatus struct {
+	queue.StageStats
+	Utilization float64 `json:"utilization"`
+}
+
+// Status is returned by the status endpoint.
+type Status struct {
+	Files  int                         `json:"files"`
+	Stages map[queue.Stage]StageStatus `json:"stages"`
+}
+
+func (s *Server) handleStatus(res http.ResponseWriter, _ *http.Request) {
+	status := Status{
+		Files:  len(s.Database.Files()),
+		Stages: make(map[queue.Stage]StageStatus),
+	}
+
+	for stage, stats := range s.Queue.Stats() {
+		status.Stages[stage] = StageStatus{
+			StageStats:  stats,
+			Utilization: stats.Utilization(),
+		}
+	}
+
+	s.writeJSON(res, status)
+}
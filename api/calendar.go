@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// icsEscaper escapes text values in iCalendar files (RFC 5545, section 3.3.11).
+var icsEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// writeICSLine writes a content line, folded after at most 75 octets.
+func writeICSLine(buf *bytes.Buffer, name, value string) {
+	line := name + ":" + value
+
+	// continuation lines start with a space
+	limit := 75
+	for len(line) > limit {
+		// do not split UTF-8 sequences
+		n := limit
+		for n > 0 && line[n]&0xc0 == 0x80 {
+			n--
+		}
+
+		buf.WriteString(line[:n])
+		buf.WriteString("\r\n ")
+		line = line[n:]
+		limit = 74
+	}
+
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+// calendarEntry is a file with a due date.
+type calendarEntry struct {
+	id   string
+	file database.File
+	due  time.Time
+}
+
+// Calendar returns an iCalendar file with an all-day event for the due date
+// of each file. Paid files are only included if paid is set.
+func Calendar(files map[string]database.File, paid bool, now time.Time) []byte {
+	var entries []calendarEntry
+
+	for id, file := range files {
+		if file.DueDate == "" {
+			continue
+		}
+
+		due, err := time.Parse("02.01.2006", file.DueDate)
+		if err != nil {
+			continue
+		}
+
+		if !paid && file.HasTag(database.TagPaid) {
+			continue
+		}
+
+		entries = append(entries, calendarEntry{id: id, file: file, due: due})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].due.Equal(entries[j].due) {
+			return entries[i].due.Before(entries[j].due)
+		}
+
+		return entries[i].id < entries[j].id
+	})
+
+	var buf bytes.Buffer
+
+	writeICSLine(&buf, "BEGIN", "VCALENDAR")
+	writeICSLine(&buf, "VERSION", "2.0")
+	writeICSLine(&buf, "PRODID", "-//nepomuk//due dates//EN")
+	writeICSLine(&buf, "CALSCALE", "GREGORIAN")
+	writeICSLine(&buf, "X-WR-CALNAME", "nepomuk due dates")
+
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	for _, entry := range entries {
+		summary := entry.file.Title
+		if entry.file.Correspondent != "" {
+			summary += " (" + entry.file.Correspondent + ")"
+		}
+
+		if entry.file.Amount != "" {
+			summary += ": " + entry.file.Amount
+		}
+
+		writeICSLine(&buf, "BEGIN", "VEVENT")
+		writeICSLine(&buf, "UID", entry.id+"@nepomuk")
+		writeICSLine(&buf, "DTSTAMP", stamp)
+		writeICSLine(&buf, "DTSTART;VALUE=DATE", entry.due.Format("20060102"))
+		writeICSLine(&buf, "DTEND;VALUE=DATE", entry.due.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(&buf, "SUMMARY", icsEscaper.Replace(summary))
+		writeICSLine(&buf, "DESCRIPTION", icsEscaper.Replace(entry.file.Correspondent+"/"+entry.file.Filename))
+		writeICSLine(&buf, "END", "VEVENT")
+	}
+
+	writeICSLine(&buf, "END", "VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// CalendarHandler returns an http.Handler which only serves the calendar
+// feed at /calendar/<token>/due.ics. Calendar applications cannot send
+// credentials in most cases, so the secret token is part of the URL.
+func (s *Server) CalendarHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /calendar/{token}/due.ics", func(res http.ResponseWriter, req *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(req.PathValue("token")), []byte(token)) != 1 {
+			http.NotFound(res, req)
+
+			return
+		}
+
+		s.handleCalendar(res, req)
+	})
+
+	return mux
+}
+
+func (s *Server) handleCalendar(res http.ResponseWriter, req *http.Request) {
+	paid := req.URL.Query().Get("paid")
+
+	data := Calendar(s.Database.Files(), paid == "1" || paid == "true", time.Now())
+
+	res.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+
+	_, err := res.Write(data)
+	if err != nil {
+		s.log.Warnf("send calendar: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCalendar(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]database.File{
+		"a": {Title: "Rechnung; Strom, März", Correspondent: "Stadtwerke", Filename: "2024-03-01 Rechnung.pdf", DueDate: "15.03.2024", Amount: "80 EUR"},
+		"b": {Title: "Beitrag", Correspondent: "Verein", DueDate: "01.03.2024", Tags: []string{"paid"}},
+		"c": {Title: "Brief", Correspondent: "Bank"},
+		"d": {Title: strings.Repeat("sehr langer Titel ", 10), Correspondent: "Bank", DueDate: "20.03.2024"},
+	}
+
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	cal := string(Calendar(files, false, now))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:a@nepomuk\r\n",
+		"DTSTART;VALUE=DATE:20240315\r\n",
+		"DTEND;VALUE=DATE:20240316\r\n",
+		`SUMMARY:Rechnung\; Strom\, März (Stadtwerke): 80 EUR` + "\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(cal, want) {
+			t.Errorf("calendar does not contain %q:\n%s", want, cal)
+		}
+	}
+
+	if strings.Contains(cal, "UID:b@") || strings.Contains(cal, "UID:c@") {
+		t.Errorf("calendar contains paid file or file without due date:\n%s", cal)
+	}
+
+	for _, line := range strings.Split(cal, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line not folded: %q", line)
+		}
+	}
+
+	cal = string(Calendar(files, true, now))
+	if !strings.Contains(cal, "UID:b@nepomuk") {
+		t.Errorf("paid file missing:\n%s", cal)
+	}
+}
+
+func TestCalendarHandler(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{Database: database.New(t.TempDir())}
+	srv.SetLogger(logrus.New())
+
+	handler := srv.CalendarHandler("0123456789abcdef")
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/calendar/0123456789abcdef/due.ics", http.StatusOK},
+		{"/calendar/0123456789abcdeg/due.ics", http.StatusNotFound},
+		{"/calendar/due.ics", http.StatusNotFound},
+		{"/api/files", http.StatusNotFound},
+		{"/api/calendar.ics", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if res.Code != test.code {
+			t.Errorf("GET %v: want status %v, got %v", test.path, test.code, res.Code)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodDelete, "/calendar/0123456789abcdef/due.ics", nil))
+
+	if res.Code == http.StatusOK {
+		t.Errorf("DELETE was accepted")
+	}
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// File describes a file in the archive.
+type File struct {
+	ID            string   `json:"id"`
+	Filename      string   `json:"filename"`
+	Correspondent string   `json:"correspondent"`
+	Date          string   `json:"date"`
+	Title         string   `json:"title"`
+	Language      string   `json:"language,omitempty"`
+	Type          string   `json:"type,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Folder        string   `json:"folder,omitempty"`
+	DueDate       string   `json:"due_date,omitempty"`
+	Amount        string   `json:"amount,omitempty"`
+	UploadedBy    string   `json:"uploaded_by,omitempty"`
+}
+
+func newFile(id string, file database.File) File {
+	return File{
+		ID:            id,
+		Filename:      file.Filename,
+		Correspondent: file.Correspondent,
+		Date:          file.Date,
+		Title:         file.Title,
+		Language:      file.Language,
+		Type:          file.Type,
+		Tags:          file.Tags,
+		Folder:        file.Folder,
+		DueDate:       file.DueDate,
+		Amount:        file.Amount,
+		UploadedBy:    file.UploadedBy,
+	}
+}
+
+// FileUpdate changes the type, tags, due date and amount of a file. Fields
+// which are nil are not modified, Tags replaces all tags. The due date is
+// given as DD.MM.YYYY or YYYY-MM-DD, an empty string removes it. If the
+// layout contains the type, changing it renames the file.
+type FileUpdate struct {
+	Type       *string  `json:"type,omitempty"`
+	DueDate    *string  `json:"due_date,omitempty"`
+	Amount     *string  `json:"amount,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	AddTags    []string `json:"add_tags,omitempty"`
+	RemoveTags []string `json:"remove_tags,omitempty"`
+}
+
+// apply changes file according to the update.
+func (u FileUpdate) apply(file *database.File) {
+	if u.Type != nil {
+		file.Type = *u.Type
+	}
+
+	if u.Tags != nil {
+		file.Tags = u.Tags
+	}
+
+	// the due date was validated before
+	if u.DueDate != nil {
+		file.DueDate = *u.DueDate
+	}
+
+	if u.Amount != nil {
+		file.Amount = strings.TrimSpace(*u.Amount)
+	}
+
+	remove := database.NormalizeTags(u.RemoveTags)
+	tags := database.NormalizeTags(append(file.Tags, u.AddTags...))
+
+	file.Tags = slices.DeleteFunc(tags, func(tag string) bool {
+		return slices.Contains(remove, tag)
+	})
+}
+
+// writeError sends an error message with the status code to the client.
+func (s *Server) writeError(res http.ResponseWriter, code int, err error) {
+	s.log.Debugf("request failed: %v", err)
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(code)
+
+	s.writeJSON(res, map[string]string{"error": err.Error()})
+}
+
+func (s *Server) handleFiles(res http.ResponseWriter, req *http.Request) {
+	q, err := database.ParseQuery(req.URL.Query().Get("q"))
+	if err != nil {
+		s.writeError(res, http.StatusBadRequest, err)
+
+		return
+	}
+
+	files := []File{}
+	for id, file := range s.Database.Query(q) {
+		files = append(files, newFile(id, file))
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Correspondent != files[j].Correspondent {
+			return files[i].Correspondent < files[j].Correspondent
+		}
+
+		return files[i].Filename < files[j].Filename
+	})
+
+	s.writeJSON(res, files)
+}
+
+func (s *Server) handleFile(res http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	file, ok := s.Database.GetFile(id)
+	if !ok {
+		s.writeError(res, http.StatusNotFound, fmt.Errorf("%v: %w", id, database.ErrNotFound))
+
+		return
+	}
+
+	s.writeJSON(res, newFile(id, file))
+}
+
+func (s *Server) handleUpdateFile(res http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	var update FileUpdate
+
+	err := json.NewDecoder(req.Body).Decode(&update)
+	if err != nil {
+		s.writeError(res, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+
+		return
+	}
+
+	if update.DueDate != nil {
+		due, err := database.ParseDueDate(*update.DueDate)
+		if err != nil {
+			s.writeError(res, http.StatusBadRequest, err)
+
+			return
+		}
+
+		update.DueDate = &due
+	}
+
+	// changing the type renames the file if the type is part of the layout
+	file, err := s.Database.UpdateAndRename(origin(req), id, update.apply)
+	if errors.Is(err, database.ErrNotFound) {
+		s.writeError(res, http.StatusNotFound, err)
+
+		return
+	}
+
+	if errors.Is(err, database.ErrInvalidType) {
+		s.writeError(res, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err != nil {
+		s.writeError(res, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	s.log.WithField("id", id).Infof("updated file: type %q, tags %v", file.Type, file.Tags)
+
+	s.writeJSON(res, newFile(id, file))
+}
+
+func (s *Server) handleTags(res http.ResponseWriter, _ *http.Request) {
+	tags := make(map[string]int)
+
+	for _, file := range s.Database.Files() {
+		for _, tag := range file.Tags {
+			tags[tag]++
+		}
+	}
+
+	s.writeJSON(res, tags)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// Version is an entry in the history of a file.
+type Version struct {
+	Version int             `json:"version"`
+	Time    time.Time       `json:"time"`
+	Origin  database.Origin `json:"origin"`
+	Fields  []string        `json:"fields"`
+	Old     *File           `json:"old,omitempty"`
+	New     *File           `json:"new,omitempty"`
+}
+
+func (s *Server) handleHistory(res http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	if s.Database.History == nil {
+		s.writeError(res, http.StatusNotFound, errors.New("history is not enabled"))
+
+		return
+	}
+
+	entries, err := s.Database.History.Entries(id)
+	if err != nil {
+		s.writeError(res, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	versions := make([]Version, 0, len(entries))
+
+	for i, entry := range entries {
+		v := Version{
+			Version: i + 1,
+			Time:    entry.Time,
+			Origin:  entry.Origin,
+			Fields:  entry.Fields,
+		}
+
+		if entry.Old != nil {
+			f := newFile(id, *entry.Old)
+			v.Old = &f
+		}
+
+		if entry.New != nil {
+			f := newFile(id, *entry.New)
+			v.New = &f
+		}
+
+		versions = append(versions, v)
+	}
+
+	s.writeJSON(res, versions)
+}
+
+// RevertRequest restores the metadata of a file from the history.
+type RevertRequest struct {
+	Version int `json:"version"`
+}
+
+func (s *Server) handleRevert(res http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	var rreq RevertRequest
+
+	err := json.NewDecoder(req.Body).Decode(&rreq)
+	if err != nil {
+		s.writeError(res, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+
+		return
+	}
+
+	o := origin(req)
+	o.Source = database.SourceRevert
+
+	file, err := s.Database.Revert(o, id, rreq.Version)
+
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		s.writeError(res, http.StatusNotFound, err)
+
+		return
+	case errors.Is(err, database.ErrInvalidVersion), errors.Is(err, os.ErrExist):
+		s.writeError(res, http.StatusBadRequest, err)
+
+		return
+	case err != nil:
+		s.writeError(res, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	s.log.WithField("id", id).Infof("reverted file to version %d", rreq.Version)
+
+	s.writeJSON(res, newFile(id, file))
+}
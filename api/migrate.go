@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// MigrateRequest starts a migration of the archive to a new layout. If Layout
+// is empty, the configured layout is used.
+type MigrateRequest struct {
+	Layout string `json:"layout,omitempty"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// MigrateResult lists the files which were (or would be) renamed.
+type MigrateResult struct {
+	Layout string          `json:"layout"`
+	Moves  []database.Move `json:"moves"`
+}
+
+func (s *Server) handleMigrate(res http.ResponseWriter, req *http.Request) {
+	var mreq MigrateRequest
+
+	err := json.NewDecoder(req.Body).Decode(&mreq)
+	if err != nil {
+		s.writeError(res, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+
+		return
+	}
+
+	layout := s.Layout
+	if mreq.Layout != "" {
+		layout, err = database.ParseLayout(mreq.Layout)
+		if err != nil {
+			s.writeError(res, http.StatusBadRequest, err)
+
+			return
+		}
+	}
+
+	if layout == nil {
+		s.writeError(res, http.StatusBadRequest, errors.New("no layout configured"))
+
+		return
+	}
+
+	result := MigrateResult{Layout: layout.String()}
+
+	if mreq.DryRun {
+		result.Moves = s.Database.PlanMigration(layout)
+		s.writeJSON(res, result)
+
+		return
+	}
+
+	result.Moves, err = s.Database.Migrate(layout)
+	if err != nil {
+		s.writeError(res, http.StatusInternalServerError, fmt.Errorf("migration failed: %w", err))
+
+		return
+	}
+
+	s.log.Infof("migrated archive to layout %q, renamed %d files", layout, len(result.Moves))
+
+	if s.OnMigrate != nil {
+		s.OnMigrate()
+	}
+
+	s.writeJSON(res, result)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// Recurring describes a correspondent which sends documents regularly.
+type Recurring struct {
+	Correspondent string           `json:"correspondent"`
+	Cadence       database.Cadence `json:"cadence"`
+	Pinned        bool             `json:"pinned"`
+	Documents     int              `json:"documents"`
+	Last          *File            `json:"last,omitempty"`
+	Expected      string           `json:"expected,omitempty"`
+	Deadline      string           `json:"deadline,omitempty"`
+	Missing       bool             `json:"missing"`
+}
+
+// CadenceRequest pins the cadence of a correspondent. An empty cadence is
+// detected from the files, "none" disables alerts for the correspondent.
+type CadenceRequest struct {
+	Cadence       string `json:"cadence"`
+	ToleranceDays int    `json:"tolerance_days,omitempty"`
+}
+
+func (s *Server) handleRecurring(res http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+
+	list := []Recurring{}
+
+	for _, r := range s.Database.Recurring(s.Unknown) {
+		item := Recurring{
+			Correspondent: r.Correspondent,
+			Cadence:       r.Cadence,
+			Pinned:        r.Pinned,
+			Documents:     r.Documents,
+			Missing:       r.Missing(now),
+		}
+
+		if r.LastID != "" {
+			last := newFile(r.LastID, r.Last)
+			item.Last = &last
+		}
+
+		if !r.Expected.IsZero() {
+			item.Expected = r.Expected.Format("02.01.2006")
+			item.Deadline = r.Deadline.Format("02.01.2006")
+		}
+
+		list = append(list, item)
+	}
+
+	s.writeJSON(res, list)
+}
+
+func (s *Server) handleSetCadence(res http.ResponseWriter, req *http.Request) {
+	correspondent := req.PathValue("correspondent")
+
+	var creq CadenceRequest
+
+	err := json.NewDecoder(req.Body).Decode(&creq)
+	if err != nil {
+		s.writeError(res, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+
+		return
+	}
+
+	var override database.CadenceOverride
+
+	if creq.Cadence != "" {
+		override.Cadence, err = database.ParseCadence(creq.Cadence)
+		if err != nil {
+			s.writeError(res, http.StatusBadRequest, err)
+
+			return
+		}
+	}
+
+	if creq.ToleranceDays < 0 {
+		s.writeError(res, http.StatusBadRequest, errors.New("tolerance_days must not be negative"))
+
+		return
+	}
+
+	override.ToleranceDays = creq.ToleranceDays
+
+	s.Database.SetCadence(correspondent, override)
+
+	s.log.WithField("correspondent", correspondent).Infof("set cadence to %+v", override)
+
+	if s.OnSettings != nil {
+		s.OnSettings()
+	}
+
+	s.handleRecurring(res, req)
+}
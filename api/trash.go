@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// TrashedFile is a file which was deleted from the archive.
+type TrashedFile struct {
+	File
+	Deleted time.Time `json:"deleted"`
+
+	// Trashed is set if the file is kept in the trash and can be restored
+	// with the API, otherwise it is restored by copying it back into the
+	// archive.
+	Trashed bool `json:"trashed"`
+}
+
+// PurgeResult lists the IDs of the files removed from the trash.
+type PurgeResult struct {
+	Purged []string `json:"purged"`
+}
+
+func (s *Server) handleDeleteFile(res http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	err := s.Database.Remove(origin(req), id)
+
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		s.writeError(res, http.StatusNotFound, err)
+
+		return
+	case errors.Is(err, database.ErrTrashDisabled):
+		s.writeError(res, http.StatusBadRequest, err)
+
+		return
+	case err != nil:
+		s.writeError(res, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	s.log.WithField("id", id).Info("moved file to trash")
+
+	s.writeJSON(res, map[string]string{"id": id})
+}
+
+func (s *Server) handleTrash(res http.ResponseWriter, _ *http.Request) {
+	trash := s.Database.Trash()
+
+	list := make([]TrashedFile, 0, len(trash))
+	for id, tomb := range trash {
+		list = append(list, TrashedFile{
+			File:    newFile(id, tomb.File),
+			Deleted: tomb.Deleted,
+			Trashed: tomb.Trashed,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Deleted.Before(list[j].Deleted)
+	})
+
+	s.writeJSON(res, list)
+}
+
+func (s *Server) handleRestore(res http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	file, err := s.Database.Restore(origin(req), id)
+
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		s.writeError(res, http.StatusNotFound, err)
+
+		return
+	case errors.Is(err, database.ErrNotTrashed), errors.Is(err, os.ErrExist):
+		s.writeError(res, http.StatusBadRequest, err)
+
+		return
+	case err != nil:
+		s.writeError(res, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	s.log.WithField("id", id).Info("restored file from trash")
+
+	s.writeJSON(res, newFile(id, file))
+}
+
+// handlePurge removes a single file or (without an ID) all files from the trash.
+func (s *Server) handlePurge(res http.ResponseWriter, req *http.Request) {
+	ids := []string{req.PathValue("id")}
+
+	if ids[0] == "" {
+		ids = ids[:0]
+		for id := range s.Database.Trash() {
+			ids = append(ids, id)
+		}
+
+		sort.Strings(ids)
+	}
+
+	result := PurgeResult{Purged: []string{}}
+
+	var err error
+
+	for _, id := range ids {
+		err = s.Database.Purge(id)
+		if err != nil {
+			break
+		}
+
+		result.Purged = append(result.Purged, id)
+	}
+
+	if len(result.Purged) > 0 && s.OnPurge != nil {
+		s.OnPurge()
+	}
+
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		s.writeError(res, http.StatusNotFound, err)
+
+		return
+	case err != nil:
+		s.writeError(res, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	s.log.Infof("purged %d files from trash", len(result.Purged))
+
+	s.writeJSON(res, result)
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fd0/nepomuk/api"
+)
+
+// Client talks to the API of a running nepomuk instance.
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for the API server listening on addr.
+func NewClient(addr string) *Client {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+
+	return &Client{
+		BaseURL: "http://" + addr,
+		HTTP:    &http.Client{},
+	}
+}
+
+// do sends a request with body encoded as JSON and decodes the response into result.
+func (c *Client) do(method, path string, body, result any) error {
+	var rd io.Reader
+
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+
+		rd = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, rd)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set(api.HeaderSource, "cli")
+	req.Header.Set(api.HeaderUser, os.Getenv("USER"))
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+
+		_ = json.NewDecoder(res.Body).Decode(&apiErr)
+
+		return fmt.Errorf("server returned %v: %v", res.Status, apiErr.Error)
+	}
+
+	err = json.NewDecoder(res.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+// clientCommands are the subcommands which query or modify a running
+// instance via the API.
+var clientCommands = map[string]func(c *Client, opts Options, args []string) error{
+	"files": func(c *Client, _ Options, args []string) error {
+		var files []api.File
+
+		err := c.do(http.MethodGet, "/api/files?q="+url.QueryEscape(strings.Join(args, " ")), nil, &files)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			printFile(file)
+		}
+
+		return nil
+	},
+	"tags": func(c *Client, _ Options, _ []string) error {
+		var tags map[string]int
+
+		err := c.do(http.MethodGet, "/api/tags", nil, &tags)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(tags))
+		for tag := range tags {
+			names = append(names, tag)
+		}
+
+		sort.Strings(names)
+
+		for _, tag := range names {
+			fmt.Printf("%-20s %d\n", tag, tags[tag])
+		}
+
+		return nil
+	},
+	"tag": func(c *Client, _ Options, args []string) error {
+		if len(args) < 2 {
+			return errors.New("usage: tag <id> <tag>...")
+		}
+
+		return c.updateFile(args[0], api.FileUpdate{AddTags: args[1:]})
+	},
+	"untag": func(c *Client, _ Options, args []string) error {
+		if len(args) < 2 {
+			return errors.New("usage: untag <id> <tag>...")
+		}
+
+		return c.updateFile(args[0], api.FileUpdate{RemoveTags: args[1:]})
+	},
+	"set-type": func(c *Client, _ Options, args []string) error {
+		if len(args) != 2 {
+			return errors.New("usage: set-type <id> <type>")
+		}
+
+		return c.updateFile(args[0], api.FileUpdate{Type: &args[1]})
+	},
+	"set-due": func(c *Client, _ Options, args []string) error {
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New("usage: set-due <id> <date> [amount]")
+		}
+
+		update := api.FileUpdate{DueDate: &args[1]}
+		if len(args) == 3 {
+			update.Amount = &args[2]
+		}
+
+		return c.updateFile(args[0], update)
+	},
+	"history": func(c *Client, _ Options, args []string) error {
+		if len(args) != 1 {
+			return errors.New("usage: history <id>")
+		}
+
+		var versions []api.Version
+
+		err := c.do(http.MethodGet, "/api/files/"+url.PathEscape(args[0])+"/history", nil, &versions)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range versions {
+			who := v.Origin.Source
+
+			switch {
+			case v.Origin.User != "":
+				who += " (" + v.Origin.User + ")"
+			case v.Origin.ReportedUser != "":
+				who += " (" + v.Origin.ReportedUser + ", client-reported)"
+			}
+
+			fmt.Printf("%3d  %v  %v  %v\n", v.Version, v.Time.Format(time.DateTime), who, strings.Join(v.Fields, ", "))
+		}
+
+		return nil
+	},
+	"revert": func(c *Client, _ Options, args []string) error {
+		if len(args) != 2 {
+			return errors.New("usage: revert <id> <version>")
+		}
+
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[1], err)
+		}
+
+		var file api.File
+
+		err = c.do(http.MethodPost, "/api/files/"+url.PathEscape(args[0])+"/revert", api.RevertRequest{Version: version}, &file)
+		if err != nil {
+			return err
+		}
+
+		printFile(file)
+
+		return nil
+	},
+	"rm": func(c *Client, _ Options, args []string) error {
+		if len(args) == 0 {
+			return errors.New("usage: rm <id>...")
+		}
+
+		for _, id := range args {
+			var result map[string]string
+
+			err := c.do(http.MethodDelete, "/api/files/"+url.PathEscape(id), nil, &result)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("moved %v to trash\n", id)
+		}
+
+		return nil
+	},
+	"trash": func(c *Client, _ Options, _ []string) error {
+		var files []api.TrashedFile
+
+		err := c.do(http.MethodGet, "/api/trash", nil, &files)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			state := "metadata only"
+			if file.Trashed {
+				state = "in trash"
+			}
+
+			fmt.Printf("%v  %v  %v/%v  (%v)\n", file.ID, file.Deleted.Format(time.DateTime), file.Correspondent, file.Filename, state)
+		}
+
+		return nil
+	},
+	"restore": func(c *Client, _ Options, args []string) error {
+		if len(args) == 0 {
+			return errors.New("usage: restore <id>...")
+		}
+
+		for _, id := range args {
+			var file api.File
+
+			err := c.do(http.MethodPost, "/api/trash/"+url.PathEscape(id)+"/restore", nil, &file)
+			if err != nil {
+				return err
+			}
+
+			printFile(file)
+		}
+
+		return nil
+	},
+	"purge": func(c *Client, _ Options, args []string) error {
+		paths := []string{"/api/trash"}
+
+		if len(args) > 0 {
+			paths = paths[:0]
+			for _, id := range args {
+				paths = append(paths, "/api/trash/"+url.PathEscape(id))
+			}
+		}
+
+		for _, path := range paths {
+			var result api.PurgeResult
+
+			err := c.do(http.MethodDelete, path, nil, &result)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range result.Purged {
+				fmt.Printf("purged %v\n", id)
+			}
+		}
+
+		return nil
+	},
+	"recurring": func(c *Client, _ Options, _ []string) error {
+		var list []api.Recurring
+
+		err := c.do(http.MethodGet, "/api/recurring", nil, &list)
+		if err != nil {
+			return err
+		}
+
+		printRecurring(list)
+
+		return nil
+	},
+	"cadence": func(c *Client, _ Options, args []string) error {
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New("usage: cadence <correspondent> <weekly|monthly|quarterly|yearly|none|auto> [tolerance days]")
+		}
+
+		req := api.CadenceRequest{Cadence: args[1]}
+		if req.Cadence == "auto" {
+			req.Cadence = ""
+		}
+
+		if len(args) == 3 {
+			days, err := strconv.Atoi(args[2])
+			if err != nil {
+				return fmt.Errorf("invalid tolerance %q: %w", args[2], err)
+			}
+
+			req.ToleranceDays = days
+		}
+
+		var list []api.Recurring
+
+		err := c.do(http.MethodPut, "/api/recurring/"+url.PathEscape(args[0]), req, &list)
+		if err != nil {
+			return err
+		}
+
+		printRecurring(list)
+
+		return nil
+	},
+	"migrate": func(c *Client, opts Options, args []string) error {
+		if len(args) > 1 {
+			return errors.New("usage: migrate [layout]")
+		}
+
+		req := api.MigrateRequest{DryRun: opts.DryRun}
+		if len(args) == 1 {
+			req.Layout = args[0]
+		}
+
+		var result api.MigrateResult
+
+		err := c.do(http.MethodPost, "/api/migrate", req, &result)
+		if err != nil {
+			return err
+		}
+
+		for _, move := range result.Moves {
+			fmt.Printf("%v -> %v\n", move.From, move.To)
+		}
+
+		if opts.DryRun {
+			fmt.Printf("would rename %d files for layout %q\n", len(result.Moves), result.Layout)
+		} else {
+			fmt.Printf("renamed %d files for layout %q\n", len(result.Moves), result.Layout)
+		}
+
+		return nil
+	},
+}
+
+// updateFile sends update for the file id and prints the result.
+func (c *Client) updateFile(id string, update api.FileUpdate) error {
+	var file api.File
+
+	err := c.do(http.MethodPatch, "/api/files/"+url.PathEscape(id), update, &file)
+	if err != nil {
+		return err
+	}
+
+	printFile(file)
+
+	return nil
+}
+
+func printFile(file api.File) {
+	fmt.Printf("%v  %v/%v", file.ID, file.Correspondent, file.Filename)
+
+	if file.Type != "" {
+		fmt.Printf("  type:%v", file.Type)
+	}
+
+	if file.DueDate != "" {
+		fmt.Printf("  due:%v", file.DueDate)
+	}
+
+	if file.Amount != "" {
+		fmt.Printf("  amount:%q", file.Amount)
+	}
+
+	for _, tag := range file.Tags {
+		fmt.Printf("  tag:%v", tag)
+	}
+
+	fmt.Println()
+}
+
+func printRecurring(list []api.Recurring) {
+	for _, r := range list {
+		cadence := string(r.Cadence)
+		if r.Pinned {
+			cadence += " (pinned)"
+		}
+
+		last := "-"
+		if r.Last != nil {
+			last = r.Last.Date
+		}
+
+		state := ""
+		if r.Missing {
+			state = "  MISSING"
+		}
+
+		fmt.Printf("%-30s %-20s %3d files  last:%v  expected:%v%v\n", r.Correspondent, cadence, r.Documents, last, r.Expected, state)
+	}
+}
+
+// runClient runs the subcommand in args against the API server.
+func runClient(opts Options, args []string) error {
+	cmd, ok := clientCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "available commands: files, tags, tag, untag, set-type, set-due, history, revert, rm, trash, restore, purge, recurring, cadence, migrate\n")
+
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return cmd(NewClient(opts.ListenAPI), opts, args[1:])
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/fd0/nepomuk/database"
+	"github.com/fd0/nepomuk/extract"
+	"github.com/fd0/nepomuk/notify"
+	"github.com/fd0/nepomuk/process"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config is the configuration file for nepomuk, it is stored as JSON.
+type Config struct {
+	Processing process.Config `json:"processing"`
+	Extract    extract.Config `json:"extract"`
+	Database   DatabaseConfig `json:"database"`
+	Notify     notify.Config  `json:"notify"`
+	WebDAV     WebDAVConfig   `json:"webdav"`
+	Calendar   CalendarConfig `json:"calendar"`
+}
+
+// CalendarConfig configures the iCalendar feed with the due dates.
+type CalendarConfig struct {
+	// Token is the secret part of the URL of the feed.
+	Token string `json:"token"`
+}
+
+// minCalendarTokenLength is the minimal length of the calendar token, so it
+// cannot be guessed.
+const minCalendarTokenLength = 16
+
+// DatabaseConfig configures how the archive is organized.
+type DatabaseConfig struct {
+	// TagLinks mirrors the tags into the directory "tags" in the archive,
+	// with a subdir of symlinks to the tagged files for each tag.
+	TagLinks bool `json:"tag_links"`
+
+	// EmbedMetadata writes the metadata into the Info dictionary and the XMP
+	// metadata of new files (except born-digital ones) with exiftool, and
+	// reads it back for files which are not in the database.
+	EmbedMetadata bool `json:"embed_metadata"`
+
+	// Sidecars writes a hidden JSON file with the metadata next to each file
+	// in the archive (".<name>.pdf.json"), the database is rebuilt from them
+	// if it is lost.
+	Sidecars bool `json:"sidecars"`
+
+	// Layout is the template for the names of the files in the archive, e.g.
+	// "{correspondent}/{year}/{date} {type} {title}.pdf".
+	Layout string `json:"layout"`
+
+	// Trash keeps deleted files and their metadata for some time.
+	Trash TrashConfig `json:"trash"`
+}
+
+// TrashConfig configures what happens with deleted files.
+type TrashConfig struct {
+	// Enabled keeps the metadata of files deleted from the archive, files
+	// deleted via the API are moved to .nepomuk/trash.
+	Enabled bool `json:"enabled"`
+
+	// RetentionDays is the number of days after which deleted files are
+	// purged, zero keeps them forever.
+	RetentionDays int `json:"retention_days"`
+}
+
+// WebDAVConfig configures the WebDAV server for uploads.
+type WebDAVConfig struct {
+	// Users may upload files, authenticated with HTTP basic auth. Without
+	// users, anybody can upload files.
+	Users []WebDAVUser `json:"users"`
+
+	// TLSCert and TLSKey are the files with the certificate and the private
+	// key, if set the server uses HTTPS.
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
+
+	// MinSize is the minimal size of an upload in bytes, smaller files are
+	// moved to the failed directory.
+	MinSize int64 `json:"min_size"`
+
+	// PollIntervalMillis and MinAgeMillis configured polling the staging
+	// directory, uploads are now passed on when they are complete. They are
+	// only decoded to reject configuration files which still set them.
+	PollIntervalMillis *int `json:"poll_interval_ms,omitempty"`
+	MinAgeMillis       *int `json:"min_age_ms,omitempty"`
+}
+
+// WebDAVUser is a user who may upload files via WebDAV.
+type WebDAVUser struct {
+	Name string `json:"name"`
+
+	// PasswordHash is the bcrypt hash of the password, e.g. created with
+	// "htpasswd -nbB user password".
+	PasswordHash string `json:"password_hash"`
+
+	// Root is the directory the user uploads to, each user only sees their
+	// own directory. It defaults to the name.
+	Root string `json:"root"`
+
+	// Correspondent is used for files uploaded by the user if none is found
+	// in the text.
+	Correspondent string `json:"correspondent"`
+
+	// Tags are added to all files uploaded by the user.
+	Tags []string `json:"tags"`
+}
+
+// RootDir returns the directory the user uploads to.
+func (u WebDAVUser) RootDir() string {
+	if u.Root == "" {
+		return u.Name
+	}
+
+	return u.Root
+}
+
+// Validate checks the WebDAV configuration for errors.
+func (c WebDAVConfig) Validate() error {
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("tls_cert and tls_key must be set together")
+	}
+
+	if c.MinSize < 0 {
+		return errors.New("min_size must not be negative")
+	}
+
+	if c.PollIntervalMillis != nil || c.MinAgeMillis != nil {
+		return errors.New("poll_interval_ms and min_age_ms are no longer supported, uploads are passed on when they are complete")
+	}
+
+	names := make(map[string]struct{})
+	roots := make(map[string]struct{})
+
+	for _, user := range c.Users {
+		if user.Name == "" || strings.Contains(user.Name, ":") {
+			return fmt.Errorf("invalid user name %q", user.Name)
+		}
+
+		if _, ok := names[user.Name]; ok {
+			return fmt.Errorf("user %q configured more than once", user.Name)
+		}
+
+		names[user.Name] = struct{}{}
+
+		_, err := bcrypt.Cost([]byte(user.PasswordHash))
+		if err != nil {
+			return fmt.Errorf("user %v: invalid password_hash: %w", user.Name, err)
+		}
+
+		root := user.RootDir()
+		if root != path.Base(root) || root == ".." || strings.HasPrefix(root, ".") || strings.HasPrefix(root, "_") {
+			return fmt.Errorf("user %v: invalid root %q", user.Name, root)
+		}
+
+		// the uploader is found from the root dir
+		if _, ok := roots[root]; ok {
+			return fmt.Errorf("user %v: root %q used more than once", user.Name, root)
+		}
+
+		roots[root] = struct{}{}
+	}
+
+	return nil
+}
+
+// Default returns the default configuration.
+func Default() Config {
+	return Config{
+		Processing: process.DefaultConfig(),
+		Database: DatabaseConfig{
+			Layout: database.DefaultLayout,
+			Trash: TrashConfig{
+				RetentionDays: 30,
+			},
+		},
+		Notify: notify.Config{
+			DiskMinFreePercent: 5,
+			Reminders:          notify.DefaultRemindersConfig(),
+			Missing:            notify.DefaultMissingConfig(),
+		},
+		WebDAV: WebDAVConfig{
+			MinSize: 1000,
+		},
+	}
+}
+
+// Load reads the configuration from filename. If the file does not exist,
+// the default configuration is returned.
+func Load(filename string) (Config, error) {
+	cfg := Default()
+
+	buf, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+
+	if err != nil {
+		return Config{}, fmt.Errorf("load config: %w", err)
+	}
+
+	err = json.Unmarshal(buf, &cfg)
+	if err != nil {
+		return Config{}, fmt.Errorf("decode config %v failed: %w", filename, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the configuration for errors.
+func (c Config) Validate() error {
+	err := c.Processing.Validate()
+	if err != nil {
+		return fmt.Errorf("processing: %w", err)
+	}
+
+	err = c.Extract.Validate()
+	if err != nil {
+		return fmt.Errorf("extract: %w", err)
+	}
+
+	_, err = database.ParseLayout(c.Database.Layout)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+
+	err = c.Notify.Validate()
+	if err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+
+	if c.Database.Trash.RetentionDays < 0 {
+		return errors.New("database: trash retention must not be negative")
+	}
+
+	err = c.WebDAV.Validate()
+	if err != nil {
+		return fmt.Errorf("webdav: %w", err)
+	}
+
+	if c.Calendar.Token != "" && len(c.Calendar.Token) < minCalendarTokenLength {
+		return fmt.Errorf("calendar: token must have at least %d characters", minCalendarTokenLength)
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Cadence is the interval in which a correspondent sends documents.
+type Cadence string
+
+// Cadences which are detected from the dates of the files.
+const (
+	CadenceWeekly    Cadence = "weekly"
+	CadenceMonthly   Cadence = "monthly"
+	CadenceQuarterly Cadence = "quarterly"
+	CadenceYearly    Cadence = "yearly"
+
+	// CadenceNone disables the detection for a correspondent.
+	CadenceNone Cadence = "none"
+)
+
+// cadenceInfo describes the interval of a cadence.
+type cadenceInfo struct {
+	months, days int
+
+	// minDays and maxDays is the range of intervals between two documents
+	// which match the cadence
+	minDays, maxDays int
+
+	// tolerance is the default number of days after the expected date until
+	// a document is considered missing
+	tolerance int
+}
+
+var cadences = map[Cadence]cadenceInfo{
+	CadenceWeekly:    {days: 7, minDays: 5, maxDays: 9, tolerance: 3},
+	CadenceMonthly:   {months: 1, minDays: 24, maxDays: 38, tolerance: 10},
+	CadenceQuarterly: {months: 3, minDays: 80, maxDays: 100, tolerance: 21},
+	CadenceYearly:    {months: 12, minDays: 330, maxDays: 400, tolerance: 45},
+}
+
+// ParseCadence checks that s is a valid cadence.
+func ParseCadence(s string) (Cadence, error) {
+	c := Cadence(s)
+	if _, ok := cadences[c]; ok || c == CadenceNone {
+		return c, nil
+	}
+
+	return "", fmt.Errorf("invalid cadence %q, use weekly, monthly, quarterly, yearly or none", s)
+}
+
+// CadenceOverride is set by the user to pin the cadence of a correspondent.
+type CadenceOverride struct {
+	// Cadence is used instead of the detected one, an empty cadence is
+	// detected from the files.
+	Cadence Cadence `yaml:"cadence"`
+
+	// ToleranceDays is the number of days after the expected date until a
+	// document is considered missing, zero uses the default of the cadence.
+	ToleranceDays int `yaml:"tolerance_days"`
+}
+
+// Recurring describes the documents a correspondent sends regularly.
+type Recurring struct {
+	Correspondent string
+	Cadence       Cadence
+
+	// Pinned is set if the cadence was configured by the user.
+	Pinned bool
+
+	// Documents is the number of files from the correspondent.
+	Documents int
+
+	// LastID and Last is the most recent file from the correspondent.
+	LastID string
+	Last   File
+
+	// Expected is the date the next document is expected, after Deadline it
+	// is considered missing. Both are zero if the cadence is none or no file
+	// has a date.
+	Expected time.Time
+	Deadline time.Time
+}
+
+// Missing returns true if the next document has not arrived in time.
+func (r Recurring) Missing(now time.Time) bool {
+	return !r.Deadline.IsZero() && now.After(r.Deadline)
+}
+
+// minDocuments is the number of documents needed to detect a cadence.
+const minDocuments = 3
+
+// maxIntervals is the number of most recent intervals used to detect a
+// cadence, so a changed cadence is picked up after a while.
+const maxIntervals = 12
+
+// DetectCadence returns the cadence of the documents with the dates, which
+// must be sorted. Documents received within a few days are counted once.
+func DetectCadence(dates []time.Time) (Cadence, bool) {
+	var intervals []int
+
+	for i := 1; i < len(dates); i++ {
+		days := int(dates[i].Sub(dates[i-1]).Hours() / 24)
+		if days < 3 {
+			continue
+		}
+
+		intervals = append(intervals, days)
+	}
+
+	if len(intervals) < minDocuments-1 {
+		return "", false
+	}
+
+	if len(intervals) > maxIntervals {
+		intervals = intervals[len(intervals)-maxIntervals:]
+	}
+
+	var (
+		best    Cadence
+		matches int
+	)
+
+	for c, info := range cadences {
+		n := 0
+
+		for _, days := range intervals {
+			if days >= info.minDays && days <= info.maxDays {
+				n++
+			}
+		}
+
+		if n > matches {
+			best, matches = c, n
+		}
+	}
+
+	// at least three out of four intervals must match
+	if matches*4 < len(intervals)*3 {
+		return "", false
+	}
+
+	return best, true
+}
+
+// Recurring analyzes the dates of the files per correspondent and returns
+// the correspondents which send documents regularly or have a cadence
+// configured, ordered by name. Correspondents in exclude are ignored.
+func (db *Database) Recurring(exclude ...string) []Recurring {
+	db.mu.Lock()
+
+	type dated struct {
+		id   string
+		file File
+		date time.Time
+	}
+
+	files := make(map[string][]dated)
+
+	for id, file := range db.Annotations {
+		if slices.Contains(exclude, file.Correspondent) {
+			continue
+		}
+
+		date, err := time.Parse("02.01.2006", file.Date)
+		if err != nil {
+			continue
+		}
+
+		files[file.Correspondent] = append(files[file.Correspondent], dated{id: id, file: file, date: date})
+	}
+
+	overrides := make(map[string]CadenceOverride, len(db.Cadences))
+	for name, o := range db.Cadences {
+		overrides[name] = o
+	}
+
+	db.mu.Unlock()
+
+	var res []Recurring
+
+	for name, o := range overrides {
+		if _, ok := files[name]; !ok && o.Cadence != "" {
+			res = append(res, Recurring{Correspondent: name, Cadence: o.Cadence, Pinned: true})
+		}
+	}
+
+	for name, list := range files {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].date.Before(list[j].date)
+		})
+
+		dates := make([]time.Time, 0, len(list))
+		for _, d := range list {
+			dates = append(dates, d.date)
+		}
+
+		last := list[len(list)-1]
+		r := Recurring{
+			Correspondent: name,
+			Documents:     len(list),
+			LastID:        last.id,
+			Last:          last.file,
+		}
+
+		o := overrides[name]
+		if o.Cadence != "" {
+			r.Cadence, r.Pinned = o.Cadence, true
+		} else {
+			var ok bool
+
+			r.Cadence, ok = DetectCadence(dates)
+			if !ok {
+				continue
+			}
+		}
+
+		info, ok := cadences[r.Cadence]
+		if ok {
+			tolerance := info.tolerance
+			if o.ToleranceDays > 0 {
+				tolerance = o.ToleranceDays
+			}
+
+			r.Expected = last.date.AddDate(0, info.months, info.days)
+			r.Deadline = r.Expected.AddDate(0, 0, tolerance)
+		}
+
+		res = append(res, r)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Correspondent < res[j].Correspondent
+	})
+
+	return res
+}
+
+// SetCadence configures the cadence for correspondent, an empty override
+// removes the configuration.
+func (db *Database) SetCadence(correspondent string, o CadenceOverride) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if o == (CadenceOverride{}) {
+		delete(db.Cadences, correspondent)
+
+		return
+	}
+
+	if db.Cadences == nil {
+		db.Cadences = make(map[string]CadenceOverride)
+	}
+
+	db.Cadences[correspondent] = o
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDetectCadence(t *testing.T) {
+	t.Parallel()
+
+	series := func(start string, n int, months, days int) []time.Time {
+		date, err := time.Parse(time.DateOnly, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var dates []time.Time
+		for i := 0; i < n; i++ {
+			dates = append(dates, date.AddDate(0, i*months, i*days))
+		}
+
+		return dates
+	}
+
+	tests := []struct {
+		dates []time.Time
+		want  Cadence
+	}{
+		{dates: series("2023-01-03", 12, 1, 0), want: CadenceMonthly},
+		{dates: series("2023-01-03", 5, 0, 7), want: CadenceWeekly},
+		{dates: series("2020-02-15", 4, 3, 0), want: CadenceQuarterly},
+		{dates: series("2019-11-30", 4, 12, 0), want: CadenceYearly},
+		{dates: series("2023-01-03", 2, 1, 0)},
+		{dates: series("2023-01-03", 6, 0, 50)},
+		// documents received on the same day are counted once
+		{dates: append(series("2023-01-03", 3, 1, 0), series("2023-03-04", 1, 0, 0)...), want: CadenceMonthly},
+	}
+
+	for i, test := range tests {
+		got, ok := DetectCadence(test.dates)
+		if ok != (test.want != "") || got != test.want {
+			t.Errorf("test %d: want %q, got %q (%v)", i, test.want, got, ok)
+		}
+	}
+}
+
+func TestRecurring(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+
+	add := func(correspondent string, date time.Time) {
+		id := fmt.Sprintf("%s-%s", correspondent, date.Format(time.DateOnly))
+		db.Annotations[id] = File{
+			Correspondent: correspondent,
+			Date:          date.Format("02.01.2006"),
+			Title:         "Kontoauszug",
+		}
+	}
+
+	start := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		add("Bank", start.AddDate(0, i, 0))
+		add("unknown", start.AddDate(0, i, 0))
+	}
+
+	add("Versicherung", start)
+	add("Versicherung", start.AddDate(0, 2, 0))
+
+	list := db.Recurring("unknown")
+	if len(list) != 1 {
+		t.Fatalf("want one recurring correspondent, got %+v", list)
+	}
+
+	bank := list[0]
+	if bank.Correspondent != "Bank" || bank.Cadence != CadenceMonthly || bank.Pinned || bank.Documents != 6 {
+		t.Errorf("unexpected result %+v", bank)
+	}
+
+	if bank.Expected.Format(time.DateOnly) != "2023-07-05" || bank.Deadline.Format(time.DateOnly) != "2023-07-15" {
+		t.Errorf("unexpected dates: expected %v, deadline %v", bank.Expected, bank.Deadline)
+	}
+
+	if bank.Missing(time.Date(2023, 7, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("missing within tolerance")
+	}
+
+	if !bank.Missing(time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("not missing after the deadline")
+	}
+
+	// pin the cadence
+	db.SetCadence("Versicherung", CadenceOverride{Cadence: CadenceYearly, ToleranceDays: 10})
+	db.SetCadence("Bank", CadenceOverride{Cadence: CadenceNone})
+
+	list = db.Recurring("unknown")
+	if len(list) != 2 {
+		t.Fatalf("want two recurring correspondents, got %+v", list)
+	}
+
+	if !list[0].Pinned || list[0].Cadence != CadenceNone || !list[0].Deadline.IsZero() {
+		t.Errorf("cadence for Bank not disabled: %+v", list[0])
+	}
+
+	if list[1].Cadence != CadenceYearly || list[1].Deadline.Format(time.DateOnly) != "2024-03-15" {
+		t.Errorf("unexpected result for pinned cadence: %+v", list[1])
+	}
+
+	// remove the override
+	db.SetCadence("Bank", CadenceOverride{})
+
+	list = db.Recurring("unknown")
+	if list[0].Cadence != CadenceMonthly || list[0].Pinned {
+		t.Errorf("override not removed: %+v", list[0])
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -18,6 +21,15 @@ import (
 // DB is the serialized data structure of a database.
 type DB struct {
 	Annotations map[string]File `yaml:"annotations"`
+
+	// Layout is the template the files in the archive are named after.
+	Layout string `yaml:"layout"`
+
+	// Tombstones keeps the metadata of deleted files if SoftDelete is set.
+	Tombstones map[string]Tombstone `yaml:"tombstones"`
+
+	// Cadences pins the cadence of correspondents, see Recurring.
+	Cadences map[string]CadenceOverride `yaml:"cadences"`
 }
 
 type Database struct {
@@ -26,6 +38,38 @@ type Database struct {
 
 	log logrus.FieldLogger
 
+	// mu protects DB, callbacks are run without holding the lock
+	mu sync.Mutex
+
+	// linksMu serializes updates of the tag links
+	linksMu sync.Mutex
+
+	// layout is the parsed DB.Layout, protected by mu
+	layout *Layout
+
+	// layoutMu is held for reading while files are placed in the archive and
+	// for writing while the archive is migrated to a new layout
+	layoutMu sync.RWMutex
+
+	// Sidecars enables sidecar files next to each file in the archive, which
+	// contain the metadata. They are used to rebuild the database.
+	Sidecars bool
+
+	// History records all changes of the metadata if set.
+	History *History
+
+	// ImportEmbedded reads the metadata embedded into PDF files (see
+	// EmbedMetadata) for files which are not in the database yet.
+	ImportEmbedded bool
+
+	// SoftDelete keeps the metadata of deleted files as tombstones, and
+	// allows moving files to the trash instead of deleting them.
+	SoftDelete bool
+
+	// Retention is the time after which deleted files are purged by
+	// PurgeExpired, zero means forever.
+	Retention time.Duration
+
 	// OnChange is called when the annotation for a file is changed.
 	OnChange func(id string, oldAnnotation, newAnnotation File) `yaml:"-"`
 }
@@ -35,6 +79,54 @@ type File struct {
 	Correspondent string `yaml:"correspondent"`
 	Date          string `yaml:"date"`
 	Title         string `yaml:"title"`
+	Language      string `yaml:"language"`
+
+	// BornDigital is set for files which were archived without OCR because
+	// they were created digitally.
+	BornDigital bool `yaml:"born_digital"`
+
+	// Original is the name of the original file (e.g. a photo) the PDF file
+	// was converted from, it is stored in the same directory.
+	Original string `yaml:"original"`
+
+	// BlankPagesRemoved is the number of blank pages removed before OCR.
+	BlankPagesRemoved int `yaml:"blank_pages_removed"`
+
+	// Type is the kind of document, e.g. "invoice" or "contract".
+	Type string `yaml:"type"`
+
+	// Tags are labels attached to the file, e.g. "tax" or "warranty".
+	Tags []string `yaml:"tags"`
+
+	// Folder is the directory below the correspondent directory the user
+	// moved the file to, e.g. "Kredit/2019". It is not part of the layout.
+	Folder string `yaml:"folder"`
+
+	// DueDate is the date (DD.MM.YYYY) an invoice must be paid, Amount the
+	// amount to pay, e.g. "49.90 EUR". Paid files have the tag "paid".
+	DueDate string `yaml:"due_date"`
+	Amount  string `yaml:"amount"`
+
+	// UploadedBy is the name of the user who uploaded the file via WebDAV.
+	UploadedBy string `yaml:"uploaded_by"`
+}
+
+// Equal returns true if f and other contain the same data.
+func (f File) Equal(other File) bool {
+	return f.Filename == other.Filename &&
+		f.Correspondent == other.Correspondent &&
+		f.Date == other.Date &&
+		f.Title == other.Title &&
+		f.Language == other.Language &&
+		f.BornDigital == other.BornDigital &&
+		f.Original == other.Original &&
+		f.BlankPagesRemoved == other.BlankPagesRemoved &&
+		f.Type == other.Type &&
+		f.Folder == other.Folder &&
+		f.DueDate == other.DueDate &&
+		f.Amount == other.Amount &&
+		f.UploadedBy == other.UploadedBy &&
+		slices.Equal(f.Tags, other.Tags)
 }
 
 // New returns a new empty database.
@@ -42,9 +134,11 @@ func New(dir string) *Database {
 	return &Database{
 		DB: DB{
 			Annotations: make(map[string]File),
+			Tombstones:  make(map[string]Tombstone),
 		},
-		Dir: dir,
-		log: logrus.StandardLogger(),
+		Dir:    dir,
+		log:    logrus.StandardLogger(),
+		layout: MustParseLayout(DefaultLayout),
 	}
 }
 
@@ -57,8 +151,13 @@ func (db *Database) SetLogger(logger logrus.FieldLogger) {
 func (db *Database) Load(filename string) error {
 	f, err := os.Open(filename)
 	if errors.Is(err, os.ErrNotExist) {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+
 		db.DB = DB{
 			Annotations: make(map[string]File),
+			Tombstones:  make(map[string]Tombstone),
+			Layout:      db.layout.String(),
 		}
 
 		return nil
@@ -68,6 +167,9 @@ func (db *Database) Load(filename string) error {
 		return fmt.Errorf("open database failed: %w", err)
 	}
 
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	db.DB = DB{}
 
 	err = json.NewDecoder(f).Decode(&db.DB)
@@ -82,11 +184,75 @@ func (db *Database) Load(filename string) error {
 		return fmt.Errorf("close database %v failed: %w", filename, err)
 	}
 
+	if db.Annotations == nil {
+		db.Annotations = make(map[string]File)
+	}
+
+	if db.Tombstones == nil {
+		db.Tombstones = make(map[string]Tombstone)
+	}
+
+	// databases written before layouts were configurable use the default
+	if db.DB.Layout == "" {
+		db.DB.Layout = DefaultLayout
+	}
+
+	db.layout, err = ParseLayout(db.DB.Layout)
+	if err != nil {
+		return fmt.Errorf("database %v: %w", filename, err)
+	}
+
 	return nil
 }
 
+// Layout returns the layout of the files in the archive.
+func (db *Database) Layout() *Layout {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.layout
+}
+
+// SetLayout sets the layout without moving any files, this is only useful
+// for an empty archive. Use Migrate to change the layout of an archive.
+func (db *Database) SetLayout(l *Layout) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.layout = l
+	db.DB.Layout = l.String()
+}
+
+// WithLayout runs fn with the current layout, the layout is not changed
+// while fn runs. This is used to place new files in the archive.
+func (db *Database) WithLayout(fn func(*Layout) error) error {
+	db.layoutMu.RLock()
+	defer db.layoutMu.RUnlock()
+
+	return fn(db.Layout())
+}
+
+// Types returns all document types used in the database.
+func (db *Database) Types() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var types []string
+
+	for _, file := range db.Annotations {
+		if file.Type != "" && !slices.Contains(types, file.Type) {
+			types = append(types, file.Type)
+		}
+	}
+
+	return types
+}
+
 // Save saves the database to filename.
 func (db *Database) Save(filename string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	f, err := os.OpenFile(filename, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
 		return fmt.Errorf("save database %v failed: %w", filename, err)
@@ -109,30 +275,115 @@ func (db *Database) Save(filename string) error {
 
 // GetFile returns the metadata for a file ID.
 func (db *Database) GetFile(id string) (File, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	a, ok := db.Annotations[id]
 
 	return a, ok
 }
 
+// Files returns a copy of all entries in the database.
+func (db *Database) Files() map[string]File {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	files := make(map[string]File, len(db.Annotations))
+	for id, file := range db.Annotations {
+		files[id] = file
+	}
+
+	return files
+}
+
 // SetFile updates the metadata for a file ID.
-func (db *Database) SetFile(id string, a File) {
+func (db *Database) SetFile(origin Origin, id string, a File) {
+	db.mu.Lock()
 	old := db.Annotations[id]
 	db.Annotations[id] = a
+	db.mu.Unlock()
+
+	db.removeFromTrash(id)
+
+	if old.Equal(a) {
+		return
+	}
+
+	db.syncSidecar(id, old, a)
+	db.recordChange(origin, id, old, a)
 
-	if db.OnChange != nil && old != a {
+	if db.OnChange != nil {
 		db.OnChange(id, old, a)
 	}
 }
 
-// Delete removes an entry from the database.
-func (db *Database) Delete(id string) {
+// ErrNotFound is returned for file IDs which are not in the database.
+var ErrNotFound = errors.New("file not found")
+
+// Update runs fn on the metadata for a file ID and stores the result. The
+// updated metadata is returned.
+func (db *Database) Update(origin Origin, id string, fn func(*File)) (File, error) {
+	db.mu.Lock()
+	old, ok := db.Annotations[id]
+	if !ok {
+		db.mu.Unlock()
+
+		return File{}, fmt.Errorf("%v: %w", id, ErrNotFound)
+	}
+
+	file := old
+	file.Tags = slices.Clone(old.Tags)
+	fn(&file)
+	db.Annotations[id] = file
+	db.mu.Unlock()
+
+	if old.Equal(file) {
+		return file, nil
+	}
+
+	db.syncSidecar(id, old, file)
+	db.recordChange(origin, id, old, file)
+
+	if db.OnChange != nil {
+		db.OnChange(id, old, file)
+	}
+
+	return file, nil
+}
+
+// Delete removes an entry from the database. With SoftDelete, the metadata is
+// kept as a tombstone.
+func (db *Database) Delete(origin Origin, id string) {
+	db.delete(origin, id, false)
+}
+
+func (db *Database) delete(origin Origin, id string, trashed bool) {
+	db.mu.Lock()
 	old, ok := db.Annotations[id]
 	if !ok {
+		db.mu.Unlock()
+
 		return
 	}
 
 	delete(db.Annotations, id)
 
+	if db.SoftDelete {
+		db.Tombstones[id] = Tombstone{
+			File:    old,
+			Deleted: time.Now(),
+			Trashed: trashed,
+		}
+	}
+	db.mu.Unlock()
+
+	db.syncSidecar(id, old, File{})
+	db.recordChange(origin, id, old, File{})
+
+	if !db.SoftDelete {
+		db.removeThumbnail(id)
+	}
+
 	if db.OnChange != nil {
 		db.OnChange(id, old, File{})
 	}
@@ -141,6 +392,9 @@ func (db *Database) Delete(id string) {
 // Scan traverses the database directory and synchronizes it with the internal
 // database.
 func (db *Database) Scan() error {
+	db.layoutMu.RLock()
+	defer db.layoutMu.RUnlock()
+
 	db.log.Infof("synchronize database and files in %v", db.Dir)
 
 	// first, insert or update all files found in the dir
@@ -155,6 +409,10 @@ func (db *Database) Scan() error {
 			continue
 		}
 
+		if skipPath(fi.Name()) {
+			continue
+		}
+
 		if !fi.IsDir() {
 			continue
 		}
@@ -168,13 +426,23 @@ func (db *Database) Scan() error {
 	}
 
 	// next, make sure all files in the db exist
-	for id, file := range db.DB.Annotations {
+	for id, file := range db.Files() {
 		filename := filepath.Join(db.Dir, file.Correspondent, file.Filename)
 
 		_, err := os.Stat(filename)
 		if os.IsNotExist(err) {
 			db.log.WithField("filename", filename).Info("delete removed file")
-			db.Delete(id)
+			db.Delete(Origin{Source: SourceScan}, id)
+		}
+	}
+
+	// write sidecars which are missing, e.g. when they were just enabled
+	if db.Sidecars {
+		for id, file := range db.Files() {
+			_, err := os.Lstat(SidecarFilename(db.path(file)))
+			if errors.Is(err, os.ErrNotExist) {
+				db.syncSidecar(id, File{}, file)
+			}
 		}
 	}
 
@@ -190,6 +458,16 @@ func (db *Database) scanSubdir(subdir string) error {
 	}
 
 	for _, fi := range files {
+		// descend into folders below the correspondent directory
+		if fi.IsDir() && !strings.HasPrefix(fi.Name(), ".") {
+			err := db.scanSubdir(filepath.Join(subdir, fi.Name()))
+			if err != nil {
+				db.log.Warnf("scan %v failed: %v", filepath.Join(subdir, fi.Name()), err)
+			}
+
+			continue
+		}
+
 		if !fi.Type().IsRegular() {
 			continue
 		}
@@ -202,7 +480,7 @@ func (db *Database) scanSubdir(subdir string) error {
 
 		filename := filepath.Join(subdir, fi.Name())
 
-		err := db.OnRename(filename)
+		err := db.onRename(Origin{Source: SourceScan}, filename)
 		if err != nil {
 			db.log.Warnf("scan file %v failed: %v", filename, err)
 		}
@@ -211,41 +489,31 @@ func (db *Database) scanSubdir(subdir string) error {
 	return nil
 }
 
-// GenerateFilename returns the filename based on the metadata. The string rnd is
-// appended to the title (before the extension) if it is not empty.
-func (f File) GenerateFilename(rnd string) (string, error) {
-	date, err := time.Parse("02.01.2006", f.Date)
-	if err != nil {
-		return "", fmt.Errorf("parse date %q failed: %w", f.Date, err)
-	}
-
-	filename := date.Format("2006-01-02")
-	if f.Title != "" {
-		filename += " " + f.Title
-	}
-
-	if rnd != "" {
-		filename += " " + rnd
-	}
-
-	filename += ".pdf"
-
-	return filename, nil
-}
-
 func (f File) String() string {
-	return fmt.Sprintf("<File %q from %q, date %v, title %q>", f.Filename, f.Correspondent, f.Date, f.Title)
+	return fmt.Sprintf("<File %q from %q, date %v, title %q, language %q>",
+		f.Filename, f.Correspondent, f.Date, f.Title, f.Language)
 }
 
 // OnDelete updates the database when a file is deleted by the user.
 func (db *Database) OnDelete(oldName string) error {
+	return db.onDelete(Origin{Source: SourceWatcher}, oldName)
+}
+
+func (db *Database) onDelete(origin Origin, oldName string) error {
+	// ignore files that are not PDF files, e.g. originals
+	if !strings.HasSuffix(oldName, ".pdf") {
+		return nil
+	}
+
 	// try to find the filename
-	filename := filepath.Base(oldName)
-	correspondent := filepath.Base(filepath.Dir(oldName))
+	correspondent, filename, err := db.splitPath(oldName)
+	if err != nil {
+		return err
+	}
 
 	log := db.log.WithField("filename", filename).WithField("correspondent", correspondent)
 
-	for id, file := range db.DB.Annotations {
+	for id, file := range db.Files() {
 		if file.Correspondent != correspondent {
 			continue
 		}
@@ -255,16 +523,32 @@ func (db *Database) OnDelete(oldName string) error {
 		}
 
 		log.Infof("delete file %v from database", id)
-		db.Delete(id)
+		db.Delete(origin, id)
 
 		return nil
 	}
 
+	// files moved to the trash are already removed from the database
+	for _, tomb := range db.Trash() {
+		if tomb.Trashed && tomb.File.Correspondent == correspondent && tomb.File.Filename == filename {
+			return nil
+		}
+	}
+
 	return fmt.Errorf("unable to find file %v in database", oldName)
 }
 
 // OnRename updates the database when a file is renamed by the user.
 func (db *Database) OnRename(newName string) error {
+	db.layoutMu.RLock()
+	defer db.layoutMu.RUnlock()
+
+	return db.onRename(Origin{Source: SourceWatcher}, newName)
+}
+
+// onRename parses newName with the current layout, db.layoutMu must be held
+// by the caller so that the layout is not changed by a migration meanwhile.
+func (db *Database) onRename(origin Origin, newName string) error {
 	// check if the new name is a file or dir
 	fi, err := os.Lstat(newName)
 	if err != nil {
@@ -283,7 +567,7 @@ func (db *Database) OnRename(newName string) error {
 		for _, entry := range entries {
 			filename := filepath.Join(newName, entry.Name())
 
-			err = db.OnRename(filename)
+			err = db.onRename(origin, filename)
 			if err != nil {
 				db.log.WithField("filename", filename).Warnf("rename failed: %v", err)
 
@@ -309,33 +593,130 @@ func (db *Database) OnRename(newName string) error {
 
 	log := db.log.WithField("id", id)
 
-	// extract new metadata from new name
-	date, title, err := ParseFilename(filepath.Base(newName))
+	correspondent, filename, err := db.splitPath(newName)
 	if err != nil {
-		return fmt.Errorf("parse new filename failed: %w", err)
+		return err
 	}
 
-	// extract new correspondent
-	correspondent := filepath.Base(filepath.Dir(newName))
+	// extract new metadata from new name
+	fields, err := db.Layout().Parse(correspondent+"/"+filename, db.Types())
+	if err != nil {
+		// fall back to the default format of file names
+		date, title, err := ParseFilename(path.Base(filename))
+		if err != nil {
+			return fmt.Errorf("parse new filename failed: %w", err)
+		}
+
+		folder := path.Dir(filename)
+		if folder == "." {
+			folder = ""
+		}
 
-	file, _ := db.GetFile(id)
+		fields = map[string]string{
+			FieldDate:   date,
+			FieldTitle:  title,
+			FieldFolder: folder,
+		}
+	}
+
+	file, ok := db.GetFile(id)
 	fileBefore := file
 
-	file.Date = date
-	file.Title = title
-	file.Filename = filepath.Base(newName)
+	if !ok {
+		var tomb Tombstone
+
+		tomb, ok = db.tombstone(id)
+		if ok {
+			log.Infof("restore metadata of deleted file")
+
+			file = tomb.File
+		}
+	}
+
+	if !ok && db.Sidecars {
+		var sidecarID string
+
+		file, sidecarID, ok, err = readSidecar(newName)
+		if err != nil {
+			log.Warnf("%v", err)
+		}
+
+		if ok {
+			log.Infof("import metadata from sidecar")
+
+			if sidecarID != id {
+				log.Infof("file was modified, sidecar belongs to ID %v", sidecarID)
+			}
+		}
+	}
+
+	if !ok && db.ImportEmbedded {
+		embedded, found, err := ReadEmbeddedMetadata(newName)
+		if err != nil {
+			log.Warnf("read embedded metadata: %v", err)
+		}
+
+		if found {
+			log.Infof("import embedded metadata")
+
+			file = embedded
+		}
+	}
+
+	file.Filename = filename
 	file.Correspondent = correspondent
 
-	if fileBefore != file {
+	// only update the fields contained in the name
+	if date, ok := fields[FieldDate]; ok {
+		file.Date = date
+	}
+
+	if title, ok := fields[FieldTitle]; ok {
+		file.Title = title
+	}
+
+	if typ, ok := fields[FieldType]; ok {
+		file.Type = typ
+	}
+
+	file.Folder = fields[FieldFolder]
+
+	if !fileBefore.Equal(file) {
 		log.WithField("file", fileBefore).Debug("before")
 		log.WithField("file", file).Debug("after")
 	}
 
-	db.SetFile(id, file)
+	db.SetFile(origin, id, file)
 
 	return nil
 }
 
+// splitPath returns the correspondent (the first directory below the archive
+// dir) and the rest of the path for a file in the archive.
+func (db *Database) splitPath(filename string) (correspondent, rest string, err error) {
+	dir, err := filepath.Abs(db.Dir)
+	if err != nil {
+		return "", "", fmt.Errorf("abs: %w", err)
+	}
+
+	filename, err = filepath.Abs(filename)
+	if err != nil {
+		return "", "", fmt.Errorf("abs: %w", err)
+	}
+
+	rel, err := filepath.Rel(dir, filename)
+	if err != nil {
+		return "", "", fmt.Errorf("rel: %w", err)
+	}
+
+	correspondent, rest, ok := strings.Cut(filepath.ToSlash(rel), "/")
+	if !ok || correspondent == ".." {
+		return "", "", fmt.Errorf("file %v is not in a correspondent directory", filename)
+	}
+
+	return correspondent, rest, nil
+}
+
 // FileID returns the ID for filename.
 func FileID(filename string) (string, error) {
 	f, err := os.Open(filename)
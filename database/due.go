@@ -0,0 +1,43 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TagPaid marks files with a due date which have been paid.
+const TagPaid = "paid"
+
+// ParseDueDate parses a due date as DD.MM.YYYY or YYYY-MM-DD and returns it
+// as DD.MM.YYYY. An empty string is returned as is, it removes the due date.
+func ParseDueDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	for _, format := range []string{"02.01.2006", "2006-01-02"} {
+		date, err := reformatDate(s, format)
+		if err == nil {
+			return date, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid due date %q, use DD.MM.YYYY or YYYY-MM-DD", s)
+}
+
+// Due returns the due date of f in the location loc. False is returned for
+// files without a (valid) due date and for paid files.
+func (f File) Due(loc *time.Location) (time.Time, bool) {
+	if f.DueDate == "" || f.HasTag(TagPaid) {
+		return time.Time{}, false
+	}
+
+	date, err := time.ParseInLocation("02.01.2006", f.DueDate, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return date, true
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// embedCreatorTool marks PDF files which contain metadata written by nepomuk.
+const embedCreatorTool = "nepomuk"
+
+// runExiftool runs exiftool with args and returns the output. Messages on
+// stderr are included in the error.
+func runExiftool(args ...string) ([]byte, error) {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+
+	cmd := exec.Command("exiftool", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("exiftool: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// EmbedMetadata writes the metadata of f into the Info dictionary and the XMP
+// metadata of the PDF file filename. The file is modified, so its ID changes.
+func EmbedMetadata(filename string, f File) error {
+	args := []string{
+		"-overwrite_original",
+		"-PDF:Title=" + f.Title,
+		"-PDF:Author=" + f.Correspondent,
+		"-PDF:Subject=" + f.Type,
+		"-PDF:Keywords=" + strings.Join(f.Tags, ", "),
+		"-XMP-dc:Title=" + f.Title,
+		"-XMP-dc:Creator=" + f.Correspondent,
+		"-XMP-dc:Type=" + f.Type,
+		"-XMP-dc:Language=" + f.Language,
+		"-XMP-dc:Subject=",
+		"-XMP-xmp:CreatorTool=" + embedCreatorTool,
+	}
+
+	date, err := time.Parse("02.01.2006", f.Date)
+	if err == nil {
+		args = append(args, "-XMP-dc:Date="+date.Format("2006:01:02"))
+	}
+
+	for _, tag := range f.Tags {
+		args = append(args, "-XMP-dc:Subject+="+tag)
+	}
+
+	_, err = runExiftool(append(args, filename)...)
+
+	return err
+}
+
+// ReadEmbeddedMetadata returns the metadata embedded into filename by
+// EmbedMetadata. If the file does not contain such metadata, false is returned.
+func ReadEmbeddedMetadata(filename string) (File, bool, error) {
+	buf, err := runExiftool("-json", "-XMP-dc:all", "-XMP-xmp:CreatorTool", filename)
+	if err != nil {
+		return File{}, false, err
+	}
+
+	var res []map[string]any
+
+	err = json.Unmarshal(buf, &res)
+	if err != nil {
+		return File{}, false, fmt.Errorf("decode exiftool output: %w", err)
+	}
+
+	if len(res) != 1 {
+		return File{}, false, nil
+	}
+
+	// values which look like numbers are returned as numbers
+	get := func(key string) string {
+		if v, ok := res[0][key]; ok {
+			return fmt.Sprint(v)
+		}
+
+		return ""
+	}
+
+	if get("CreatorTool") != embedCreatorTool {
+		return File{}, false, nil
+	}
+
+	f := File{
+		Title:         get("Title"),
+		Correspondent: get("Creator"),
+		Type:          get("Type"),
+		Language:      get("Language"),
+	}
+
+	// the date may contain a time
+	date, err := time.Parse("2006:01:02", strings.SplitN(get("Date"), " ", 2)[0])
+	if err == nil {
+		f.Date = date.Format("02.01.2006")
+	}
+
+	// exiftool returns a single tag as a value and several as a list
+	switch subject := res[0]["Subject"].(type) {
+	case nil:
+	case []any:
+		for _, tag := range subject {
+			f.Tags = append(f.Tags, fmt.Sprint(tag))
+		}
+	default:
+		f.Tags = []string{fmt.Sprint(subject)}
+	}
+
+	f.Tags = NormalizeTags(f.Tags)
+
+	return f, true, nil
+}
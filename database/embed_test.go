@@ -0,0 +1,65 @@
+package database
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEmbedMetadata(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("exiftool not found")
+	}
+
+	buf, err := os.ReadFile(filepath.Join("testdata", "minimal.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "test.pdf")
+
+	err = os.WriteFile(filename, buf, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok, err := ReadEmbeddedMetadata(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatal("metadata found in file without embedded metadata")
+	}
+
+	want := File{
+		Title:         "Invoice 2023",
+		Correspondent: "Bank",
+		Type:          "invoice",
+		Language:      "deu",
+		Date:          "15.03.2023",
+		Tags:          []string{"paid", "tax"},
+	}
+
+	err = EmbedMetadata(filename, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := ReadEmbeddedMetadata(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatal("embedded metadata not found")
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
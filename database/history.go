@@ -0,0 +1,234 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sources of changes to the metadata.
+const (
+	SourceExtracter = "extracter"
+	SourceWatcher   = "watcher"
+	SourceScan      = "scan"
+	SourceAPI       = "api"
+	SourceCLI       = "cli"
+	SourceMigration = "migration"
+	SourceRevert    = "revert"
+)
+
+// Origin describes who or what changed the metadata of a file.
+type Origin struct {
+	Source string `json:"source"`
+
+	// User is the authenticated user who made the change.
+	User string `json:"user,omitempty"`
+
+	// ReportedUser is the user name sent by the client. It is not
+	// authenticated, so it is only informational.
+	ReportedUser string `json:"reported_user,omitempty"`
+}
+
+// HistoryEntry records a change of the metadata of a file. Old is nil for new
+// files, New is nil for deleted files.
+type HistoryEntry struct {
+	Time   time.Time `json:"time"`
+	ID     string    `json:"id"`
+	Origin Origin    `json:"origin"`
+	Fields []string  `json:"fields"`
+	Old    *File     `json:"old,omitempty"`
+	New    *File     `json:"new,omitempty"`
+}
+
+// changedFields returns the names of the fields which differ between a and b.
+func changedFields(a, b File) []string {
+	var fields []string
+
+	add := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+
+	add("filename", a.Filename != b.Filename)
+	add(FieldCorrespondent, a.Correspondent != b.Correspondent)
+	add(FieldFolder, a.Folder != b.Folder)
+	add(FieldDate, a.Date != b.Date)
+	add(FieldTitle, a.Title != b.Title)
+	add(FieldType, a.Type != b.Type)
+	add("tags", !(File{Tags: a.Tags}).Equal(File{Tags: b.Tags}))
+	add("language", a.Language != b.Language)
+	add("original", a.Original != b.Original)
+	add("born_digital", a.BornDigital != b.BornDigital)
+	add("blank_pages_removed", a.BlankPagesRemoved != b.BlankPagesRemoved)
+	add("due_date", a.DueDate != b.DueDate)
+	add("amount", a.Amount != b.Amount)
+	add("uploaded_by", a.UploadedBy != b.UploadedBy)
+
+	return fields
+}
+
+// History is an append-only log of all changes to the metadata, stored as
+// one JSON object per line.
+type History struct {
+	Filename string
+
+	mu sync.Mutex
+}
+
+// NewHistory returns a history stored in filename.
+func NewHistory(filename string) *History {
+	return &History{Filename: filename}
+}
+
+// Append adds an entry to the history.
+func (h *History) Append(entry HistoryEntry) error {
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode history entry: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.OpenFile(h.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("open history: %w", err)
+	}
+
+	_, err = f.Write(append(buf, '\n'))
+	if err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("write history: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("close history: %w", err)
+	}
+
+	return nil
+}
+
+// Entries returns all entries for the file ID, oldest first.
+func (h *History) Entries(id string) ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.Open(h.Filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var entries []HistoryEntry
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1024*1024)
+
+	for sc.Scan() {
+		var entry HistoryEntry
+
+		err := json.Unmarshal(sc.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("decode history entry: %w", err)
+		}
+
+		if entry.ID == id {
+			entries = append(entries, entry)
+		}
+	}
+
+	err = sc.Err()
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// recordChange appends an entry to the history, if enabled.
+func (db *Database) recordChange(origin Origin, id string, oldFile, newFile File) {
+	if db.History == nil {
+		return
+	}
+
+	entry := HistoryEntry{
+		Time:   time.Now(),
+		ID:     id,
+		Origin: origin,
+		Fields: changedFields(oldFile, newFile),
+	}
+
+	if oldFile.Filename != "" {
+		entry.Old = &oldFile
+	}
+
+	if newFile.Filename != "" {
+		entry.New = &newFile
+	}
+
+	err := db.History.Append(entry)
+	if err != nil {
+		db.log.WithField("id", id).Warnf("%v", err)
+	}
+}
+
+// ErrInvalidVersion is returned by Revert for versions which do not exist.
+var ErrInvalidVersion = errors.New("invalid version")
+
+// Revert restores the metadata of file id to version (the state after the
+// change with this number in the history, starting at 1). The file is renamed
+// according to the current layout, the original file is moved along.
+func (db *Database) Revert(origin Origin, id string, version int) (File, error) {
+	if db.History == nil {
+		return File{}, errors.New("history is not enabled")
+	}
+
+	entries, err := db.History.Entries(id)
+	if err != nil {
+		return File{}, err
+	}
+
+	if version < 1 || version > len(entries) || entries[version-1].New == nil {
+		return File{}, fmt.Errorf("%w %d for %v", ErrInvalidVersion, version, id)
+	}
+
+	target := *entries[version-1].New
+
+	db.layoutMu.RLock()
+	defer db.layoutMu.RUnlock()
+
+	current, ok := db.GetFile(id)
+	if !ok {
+		return File{}, fmt.Errorf("%v: %w", id, ErrNotFound)
+	}
+
+	// the archive may have been migrated to another layout since, so the name
+	// is built again
+	target.Filename, err = db.freeName(db.Layout(), current, target)
+	if err != nil {
+		return File{}, fmt.Errorf("revert: %w", err)
+	}
+
+	target, err = db.moveFile(id, current, target)
+	if err != nil {
+		return File{}, fmt.Errorf("revert: %w", err)
+	}
+
+	db.SetFile(origin, id, target)
+
+	return target, nil
+}
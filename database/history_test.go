@@ -0,0 +1,159 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHistoryRevert(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+	db.History = NewHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	err := os.MkdirAll(filepath.Join(db.Dir, "Bank"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(db.Dir, "Bank", "2023-01-02 foo.pdf")
+	write(t, filename, "foo")
+
+	id, err := FileID(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := File{
+		Correspondent: "Bank",
+		Filename:      "2023-01-02 foo.pdf",
+		Date:          "02.01.2023",
+		Title:         "foo",
+	}
+	db.SetFile(Origin{Source: SourceExtracter}, id, file)
+
+	_, err = db.Update(Origin{Source: SourceAPI, User: "alice"}, id, func(f *File) {
+		f.Tags = []string{"tax"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// setting the same data again is not recorded
+	_, err = db.Update(Origin{Source: SourceAPI}, id, func(f *File) {
+		f.Tags = []string{"tax"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newFilename := filepath.Join(db.Dir, "Bank", "2023-01-02 bar.pdf")
+	rename(t, filename, newFilename)
+
+	err = db.OnRename(newFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := db.History.Entries(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sources [][]string
+	for _, entry := range entries {
+		sources = append(sources, append([]string{entry.Origin.Source}, entry.Fields...))
+	}
+
+	want := [][]string{
+		{SourceExtracter, "filename", FieldCorrespondent, FieldDate, FieldTitle},
+		{SourceAPI, "tags"},
+		{SourceWatcher, "filename", FieldTitle},
+	}
+
+	if !reflect.DeepEqual(sources, want) {
+		t.Fatalf("wrong history, want %v, got %v", want, sources)
+	}
+
+	if entries[1].Origin.User != "alice" {
+		t.Errorf("wrong user, want alice, got %q", entries[1].Origin.User)
+	}
+
+	_, err = db.Revert(Origin{Source: SourceRevert}, id, 4)
+	if !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("expected ErrInvalidVersion, got %v", err)
+	}
+
+	// revert to the state before the rename
+	got, err := db.Revert(Origin{Source: SourceRevert}, id, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file.Tags = []string{"tax"}
+	if !got.Equal(file) {
+		t.Errorf("want %+v, got %+v", file, got)
+	}
+
+	_, err = os.Stat(filename)
+	if err != nil {
+		t.Errorf("file was not renamed back: %v", err)
+	}
+
+	entries, err = db.History.Entries(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 4 || entries[3].Origin.Source != SourceRevert {
+		t.Errorf("revert not recorded: %+v", entries)
+	}
+}
+
+func TestHistoryRevertMigrated(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+	db.History = NewHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	err := os.MkdirAll(filepath.Join(db.Dir, "Bank"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, filepath.Join(db.Dir, "Bank", "2023-01-02 foo.pdf"), "foo")
+	write(t, filepath.Join(db.Dir, "Bank", "2023-01-02 foo.jpg"), "original")
+
+	db.SetFile(Origin{Source: SourceExtracter}, "1", File{
+		Correspondent: "Bank",
+		Filename:      "2023-01-02 foo.pdf",
+		Original:      "2023-01-02 foo.jpg",
+		Date:          "02.01.2023",
+		Title:         "foo",
+	})
+
+	_, err = db.Migrate(MustParseLayout("{correspondent}/{year}/{date} {title}.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first version has the name from before the migration
+	got, err := db.Revert(Origin{Source: SourceRevert}, "1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Filename != "2023/2023-01-02 foo.pdf" || got.Original != "2023-01-02 foo.jpg" {
+		t.Errorf("wrong names %q and %q", got.Filename, got.Original)
+	}
+
+	for _, name := range []string{"2023/2023-01-02 foo.pdf", "2023/2023-01-02 foo.jpg"} {
+		_, err = os.Stat(filepath.Join(db.Dir, "Bank", filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("file not found: %v", err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultLayout is the layout of the archive unless configured otherwise.
+const DefaultLayout = "{correspondent}/{date} {title}.pdf"
+
+// Fields which can be used in a layout.
+const (
+	FieldCorrespondent = "correspondent"
+	FieldDate          = "date"
+	FieldYear          = "year"
+	FieldMonth         = "month"
+	FieldDay           = "day"
+	FieldType          = "type"
+	FieldTitle         = "title"
+
+	// FieldFolder is returned by Parse for files in a folder below the
+	// correspondent directory, it cannot be used in a layout.
+	FieldFolder = "folder"
+)
+
+var layoutFieldRegex = regexp.MustCompile(`\{([a-z]+)\}`)
+
+var multipleSpaces = regexp.MustCompile(` {2,}`)
+
+// Layout describes where files are stored in the archive, it is a template
+// like "{correspondent}/{year}/{date} {type} {title}.pdf". The first
+// directory is always the correspondent.
+type Layout struct {
+	template string
+	fields   []string
+}
+
+// ParseLayout parses a layout template.
+func ParseLayout(template string) (*Layout, error) {
+	rest, ok := strings.CutPrefix(template, "{"+FieldCorrespondent+"}/")
+	if !ok {
+		return nil, fmt.Errorf("layout %q must start with {correspondent}/", template)
+	}
+
+	if !strings.HasSuffix(template, ".pdf") {
+		return nil, fmt.Errorf("layout %q must end with .pdf", template)
+	}
+
+	if path.Clean(template) != template || strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("layout %q is not a clean relative path", template)
+	}
+
+	l := &Layout{template: template}
+
+	for _, match := range layoutFieldRegex.FindAllStringSubmatch(rest, -1) {
+		switch match[1] {
+		case FieldDate, FieldYear, FieldMonth, FieldDay, FieldType, FieldTitle:
+		case FieldCorrespondent:
+			return nil, errors.New("layout must contain {correspondent} only once")
+		default:
+			return nil, fmt.Errorf("unknown field %q in layout", match[1])
+		}
+
+		if slices.Contains(l.fields, match[1]) {
+			return nil, fmt.Errorf("field %q used more than once in layout", match[1])
+		}
+
+		l.fields = append(l.fields, match[1])
+	}
+
+	return l, nil
+}
+
+// MustParseLayout is like ParseLayout but panics on errors.
+func MustParseLayout(template string) *Layout {
+	l, err := ParseLayout(template)
+	if err != nil {
+		panic(err)
+	}
+
+	return l
+}
+
+func (l *Layout) String() string {
+	return l.template
+}
+
+// Has returns true if the layout contains field.
+func (l *Layout) Has(field string) bool {
+	return slices.Contains(l.fields, field)
+}
+
+// cleanName removes duplicate spaces (e.g. caused by empty fields) from a
+// path component and replaces slashes.
+func cleanName(s string) string {
+	s = strings.ReplaceAll(s, "/", "-")
+	s = multipleSpaces.ReplaceAllString(s, " ")
+	s = strings.ReplaceAll(s, " .pdf", ".pdf")
+
+	return strings.TrimSpace(s)
+}
+
+// Filename returns the name of f according to the layout, relative to the
+// correspondent directory. Files in a folder stay there. The string rnd is
+// appended to the name (before the extension) if it is not empty.
+func (l *Layout) Filename(f File, rnd string) (string, error) {
+	date, err := time.Parse("02.01.2006", f.Date)
+	if err != nil {
+		return "", fmt.Errorf("parse date %q failed: %w", f.Date, err)
+	}
+
+	values := map[string]string{
+		FieldDate:  date.Format("2006-01-02"),
+		FieldYear:  date.Format("2006"),
+		FieldMonth: date.Format("01"),
+		FieldDay:   date.Format("02"),
+		FieldType:  f.Type,
+		FieldTitle: f.Title,
+	}
+
+	rest := strings.TrimPrefix(l.template, "{"+FieldCorrespondent+"}/")
+	components := strings.Split(rest, "/")
+
+	for i, component := range components {
+		component = layoutFieldRegex.ReplaceAllStringFunc(component, func(field string) string {
+			// values must not introduce new directories
+			return strings.ReplaceAll(values[field[1:len(field)-1]], "/", "-")
+		})
+
+		if i == len(components)-1 && rnd != "" {
+			component = strings.TrimSuffix(component, ".pdf") + " " + rnd + ".pdf"
+		}
+
+		component = cleanName(component)
+		if component == "" || component == "." || component == ".." {
+			component = "_"
+		}
+
+		components[i] = component
+	}
+
+	return path.Join(f.Folder, path.Join(components...)), nil
+}
+
+// fieldPattern returns the regular expression for a field. Types must be
+// known to distinguish them from the title.
+func fieldPattern(field string, types []string) string {
+	switch field {
+	case FieldDate:
+		return `(\d{4}-\d{2}-\d{2})?`
+	case FieldYear:
+		return `(\d{4})`
+	case FieldMonth, FieldDay:
+		return `(\d{2})`
+	case FieldType:
+		types = slices.Clone(types)
+
+		// try longer types first
+		sort.Slice(types, func(i, j int) bool {
+			return len(types[i]) > len(types[j])
+		})
+
+		quoted := make([]string, 0, len(types))
+		for _, t := range types {
+			if t != "" {
+				quoted = append(quoted, regexp.QuoteMeta(t))
+			}
+		}
+
+		// the type must be followed by a word boundary, so "invoice" is not
+		// recognized in the title "invoices 2023"
+		return `((?:(?:` + strings.Join(quoted, "|") + `)\b)?)`
+	default:
+		return `([^/]*?)`
+	}
+}
+
+// regexp returns a regular expression for the part of the layout below the
+// correspondent directory.
+func (l *Layout) regexp(types []string) (*regexp.Regexp, []string) {
+	rest := strings.TrimPrefix(l.template, "{"+FieldCorrespondent+"}/")
+
+	var (
+		pattern strings.Builder
+		fields  []string
+	)
+
+	pattern.WriteString("^")
+
+	last := 0
+	for _, loc := range layoutFieldRegex.FindAllStringSubmatchIndex(rest, -1) {
+		pattern.WriteString(literalPattern(rest[last:loc[0]]))
+
+		field := rest[loc[2]:loc[3]]
+		fields = append(fields, field)
+		pattern.WriteString(fieldPattern(field, types))
+
+		last = loc[1]
+	}
+
+	// the suffix appended for unique names ends up in the last field
+	pattern.WriteString(literalPattern(rest[last:]))
+	pattern.WriteString("$")
+
+	return regexp.MustCompile(pattern.String()), fields
+}
+
+// literalPattern matches the literal text s, spaces are optional because
+// they are collapsed for empty fields.
+func literalPattern(s string) string {
+	parts := strings.Split(s, " ")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+
+	return strings.Join(parts, " *")
+}
+
+// ErrLayoutMismatch is returned by Parse for paths which do not match the layout.
+var ErrLayoutMismatch = errors.New("path does not match layout")
+
+// Parse extracts the metadata from the name of a file relative to the archive
+// directory. Types lists the document types which can be recognized. The
+// result contains the fields in the layout, the correspondent and the folder,
+// which consists of the directories between the correspondent directory and
+// the part matching the layout. The date is returned as DD.MM.YYYY.
+func (l *Layout) Parse(name string, types []string) (map[string]string, error) {
+	correspondent, rest, ok := strings.Cut(name, "/")
+	if !ok {
+		return nil, ErrLayoutMismatch
+	}
+
+	re, fields := l.regexp(types)
+
+	// strip directories from the start until the rest matches the layout
+	folder := ""
+
+	matches := re.FindStringSubmatch(rest)
+	for matches == nil {
+		dir, remainder, ok := strings.Cut(rest, "/")
+		if !ok {
+			return nil, ErrLayoutMismatch
+		}
+
+		folder = path.Join(folder, dir)
+		rest = remainder
+		matches = re.FindStringSubmatch(rest)
+	}
+
+	res := map[string]string{
+		FieldCorrespondent: correspondent,
+		FieldFolder:        folder,
+	}
+
+	for i, field := range fields {
+		res[field] = strings.TrimSpace(matches[i+1])
+	}
+
+	if res[FieldDate] != "" {
+		date, err := reformatDate(res[FieldDate], "2006-01-02")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrLayoutMismatch, err)
+		}
+
+		res[FieldDate] = date
+	} else if l.Has(FieldYear) && l.Has(FieldMonth) && l.Has(FieldDay) {
+		date, err := reformatDate(res[FieldYear]+"-"+res[FieldMonth]+"-"+res[FieldDay], "2006-01-02")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrLayoutMismatch, err)
+		}
+
+		res[FieldDate] = date
+	}
+
+	return res, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	t.Parallel()
+
+	types := []string{"invoice", "notice"}
+
+	tests := []struct {
+		layout   string
+		file     File
+		filename string
+		fields   map[string]string
+	}{
+		{
+			layout:   DefaultLayout,
+			file:     File{Correspondent: "Bank", Date: "02.01.2023", Title: "Kontoauszug"},
+			filename: "2023-01-02 Kontoauszug.pdf",
+			fields:   map[string]string{"folder": "", "correspondent": "Bank", "date": "02.01.2023", "title": "Kontoauszug"},
+		},
+		{
+			layout:   DefaultLayout,
+			file:     File{Correspondent: "Bank", Date: "02.01.2023"},
+			filename: "2023-01-02.pdf",
+			fields:   map[string]string{"folder": "", "correspondent": "Bank", "date": "02.01.2023", "title": ""},
+		},
+		{
+			layout:   "{correspondent}/{year}/{date} {type} {title}.pdf",
+			file:     File{Correspondent: "Finanzamt", Date: "31.12.2022", Type: "notice", Title: "Steuerbescheid"},
+			filename: "2022/2022-12-31 notice Steuerbescheid.pdf",
+			fields: map[string]string{"folder": "", "correspondent": "Finanzamt", "year": "2022", "date": "31.12.2022",
+				"type": "notice", "title": "Steuerbescheid"},
+		},
+		{
+			layout:   "{correspondent}/{year}/{date} {type} {title}.pdf",
+			file:     File{Correspondent: "Shop", Date: "31.12.2022", Title: "invoices 2022"},
+			filename: "2022/2022-12-31 invoices 2022.pdf",
+			fields: map[string]string{"folder": "", "correspondent": "Shop", "year": "2022", "date": "31.12.2022",
+				"type": "", "title": "invoices 2022"},
+		},
+		{
+			layout:   "{correspondent}/{year}/{month}/{day} {title}.pdf",
+			file:     File{Correspondent: "Shop", Date: "05.03.2021", Title: "a/b"},
+			filename: "2021/03/05 a-b.pdf",
+			fields: map[string]string{"folder": "", "correspondent": "Shop", "year": "2021", "month": "03", "day": "05",
+				"date": "05.03.2021", "title": "a-b"},
+		},
+		{
+			layout:   "{correspondent}/{year}/{date} {title}.pdf",
+			file:     File{Correspondent: "Bank", Date: "02.01.2019", Title: "Vertrag", Folder: "Kredit/Haus"},
+			filename: "Kredit/Haus/2019/2019-01-02 Vertrag.pdf",
+			fields: map[string]string{"folder": "Kredit/Haus", "correspondent": "Bank", "year": "2019",
+				"date": "02.01.2019", "title": "Vertrag"},
+		},
+	}
+
+	for _, test := range tests {
+		l, err := ParseLayout(test.layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filename, err := l.Filename(test.file, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if filename != test.filename {
+			t.Errorf("layout %q: want filename %q, got %q", test.layout, test.filename, filename)
+		}
+
+		fields, err := l.Parse(test.file.Correspondent+"/"+filename, types)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("layout %q: want fields %v, got %v", test.layout, test.fields, fields)
+		}
+	}
+}
+
+func TestParseLayoutInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, layout := range []string{
+		"{date} {title}.pdf",
+		"{correspondent}/{date} {title}",
+		"{correspondent}/{foo}.pdf",
+		"{correspondent}/../{title}.pdf",
+		"{correspondent}/{title} {title}.pdf",
+	} {
+		_, err := ParseLayout(layout)
+		if err == nil {
+			t.Errorf("layout %q: expected error", layout)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Move describes a file which is renamed when the archive is migrated to a
+// new layout. The names are relative to the archive directory.
+type Move struct {
+	ID   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// planMigration returns the moves needed to change the archive to layout l
+// together with the updated files.
+func (db *Database) planMigration(l *Layout) ([]Move, map[string]File) {
+	files := db.Files()
+
+	ids := make([]string, 0, len(files))
+	for id := range files {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	// names which are in use, either by files already at the right place or by
+	// files which are not in the database
+	taken := make(map[string]bool)
+	sources := make(map[string]bool)
+
+	for _, id := range ids {
+		sources[path.Join(files[id].Correspondent, files[id].Filename)] = true
+	}
+
+	target := make(map[string]string)
+
+	for _, id := range ids {
+		file := files[id]
+		from := path.Join(file.Correspondent, file.Filename)
+
+		name, err := l.Filename(file, "")
+		if err != nil {
+			db.log.WithField("id", id).Warnf("keep %v: %v", from, err)
+
+			target[id] = from
+			taken[from] = true
+
+			continue
+		}
+
+		target[id] = path.Join(file.Correspondent, name)
+		if target[id] == from {
+			taken[from] = true
+		}
+	}
+
+	var moves []Move
+
+	updated := make(map[string]File)
+
+	for _, id := range ids {
+		file := files[id]
+		from := path.Join(file.Correspondent, file.Filename)
+
+		if target[id] == from {
+			continue
+		}
+
+		// find a unique name
+		to := target[id]
+		for counter := 1; ; counter++ {
+			_, err := os.Lstat(filepath.Join(db.Dir, filepath.FromSlash(to)))
+			exists := err == nil && !sources[to]
+
+			if !taken[to] && !exists {
+				break
+			}
+
+			name, _ := l.Filename(file, fmt.Sprintf("- %d", counter))
+			to = path.Join(file.Correspondent, name)
+		}
+
+		taken[to] = true
+		moves = append(moves, Move{ID: id, From: from, To: to})
+
+		file.Filename = strings.TrimPrefix(to, file.Correspondent+"/")
+
+		// the original file is kept next to the PDF file
+		_, err := os.Lstat(filepath.Join(db.Dir, filepath.FromSlash(path.Join(path.Dir(from), file.Original))))
+		if file.Original != "" && err == nil {
+			original := strings.TrimSuffix(path.Base(to), ".pdf") + path.Ext(file.Original)
+			moves = append(moves, Move{
+				ID:   id,
+				From: path.Join(path.Dir(from), file.Original),
+				To:   path.Join(path.Dir(to), original),
+			})
+
+			file.Original = original
+		}
+
+		updated[id] = file
+	}
+
+	return moves, updated
+}
+
+// PlanMigration returns the files which would be renamed by Migrate.
+func (db *Database) PlanMigration(l *Layout) []Move {
+	db.layoutMu.Lock()
+	defer db.layoutMu.Unlock()
+
+	moves, _ := db.planMigration(l)
+
+	return moves
+}
+
+// Migrate renames all files in the archive according to layout l. If a file
+// cannot be renamed, all files are moved back and the archive stays
+// unchanged. The database must be saved afterwards.
+func (db *Database) Migrate(l *Layout) ([]Move, error) {
+	db.layoutMu.Lock()
+	defer db.layoutMu.Unlock()
+
+	moves, updated := db.planMigration(l)
+
+	db.log.Infof("migrate archive to layout %q, rename %d files", l, len(moves))
+
+	abs := func(name string) string {
+		return filepath.Join(db.Dir, filepath.FromSlash(name))
+	}
+
+	// first rename all files to temporary names, so files can swap names
+	temp := make([]string, len(moves))
+	for i, move := range moves {
+		temp[i] = filepath.Join(filepath.Dir(abs(move.From)), fmt.Sprintf(".nepomuk-migrate-%d", i))
+	}
+
+	var (
+		renamed []int
+		placed  []int
+		err     error
+	)
+
+	for i, move := range moves {
+		err = os.Rename(abs(move.From), temp[i])
+		if err != nil {
+			err = fmt.Errorf("rename %v: %w", move.From, err)
+
+			break
+		}
+
+		renamed = append(renamed, i)
+	}
+
+	if err == nil {
+		for i, move := range moves {
+			err = os.MkdirAll(filepath.Dir(abs(move.To)), 0770)
+			if err != nil {
+				err = fmt.Errorf("create dir for %v: %w", move.To, err)
+
+				break
+			}
+
+			// rename() replaces existing files, so check before
+			_, err = os.Lstat(abs(move.To))
+			if err == nil {
+				err = fmt.Errorf("%v: %w", move.To, os.ErrExist)
+
+				break
+			}
+
+			err = os.Rename(temp[i], abs(move.To))
+			if err != nil {
+				err = fmt.Errorf("rename %v -> %v: %w", move.From, move.To, err)
+
+				break
+			}
+
+			placed = append(placed, i)
+		}
+	}
+
+	if err != nil {
+		db.log.Warnf("migration failed, roll back: %v", err)
+
+		for _, i := range placed {
+			rberr := os.Rename(abs(moves[i].To), temp[i])
+			if rberr != nil {
+				err = errors.Join(err, fmt.Errorf("roll back %v: %w", moves[i].To, rberr))
+			}
+		}
+
+		for _, i := range renamed {
+			rberr := os.Rename(temp[i], abs(moves[i].From))
+			if rberr != nil {
+				err = errors.Join(err, fmt.Errorf("roll back %v: %w", moves[i].From, rberr))
+			}
+		}
+
+		db.removeEmptyDirs(moves, func(m Move) string { return m.To })
+
+		return nil, err
+	}
+
+	db.mu.Lock()
+	old := make(map[string]File, len(updated))
+	for id, file := range updated {
+		old[id] = db.Annotations[id]
+		db.Annotations[id] = file
+	}
+
+	db.layout = l
+	db.DB.Layout = l.String()
+	db.mu.Unlock()
+
+	for id, file := range updated {
+		db.syncSidecar(id, old[id], file)
+		db.recordChange(Origin{Source: SourceMigration}, id, old[id], file)
+	}
+
+	db.removeEmptyDirs(moves, func(m Move) string { return m.From })
+
+	return moves, nil
+}
+
+// removeEmptyDirs removes the directories below the correspondent dirs of the
+// moves which are empty.
+func (db *Database) removeEmptyDirs(moves []Move, name func(Move) string) {
+	for _, move := range moves {
+		for dir := path.Dir(name(move)); strings.Contains(dir, "/"); dir = path.Dir(dir) {
+			// only succeeds for empty directories
+			err := os.Remove(filepath.Join(db.Dir, filepath.FromSlash(dir)))
+			if err != nil {
+				break
+			}
+		}
+	}
+}
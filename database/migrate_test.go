@@ -0,0 +1,77 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+
+	files := map[string]File{
+		"1": {Correspondent: "Bank", Filename: "2023-01-02 foo.pdf", Date: "02.01.2023", Title: "foo"},
+		"2": {Correspondent: "Bank", Filename: "2022-05-06 bar.pdf", Date: "06.05.2022", Title: "bar", Type: "invoice"},
+	}
+
+	for id, file := range files {
+		err := os.MkdirAll(filepath.Join(db.Dir, file.Correspondent), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		write(t, filepath.Join(db.Dir, file.Correspondent, file.Filename), id)
+		db.SetFile(Origin{}, id, file)
+	}
+
+	l := MustParseLayout("{correspondent}/{year}/{date} {type} {title}.pdf")
+
+	moves, err := db.Migrate(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(moves) != 2 {
+		t.Errorf("want 2 moves, got %v", moves)
+	}
+
+	for id, want := range map[string]string{"1": "2023/2023-01-02 foo.pdf", "2": "2022/2022-05-06 invoice bar.pdf"} {
+		file, _ := db.GetFile(id)
+		if file.Filename != want {
+			t.Errorf("file %v: want filename %q, got %q", id, want, file.Filename)
+		}
+
+		buf, err := os.ReadFile(filepath.Join(db.Dir, "Bank", filepath.FromSlash(want)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(buf) != id {
+			t.Errorf("file %v has wrong content %q", want, buf)
+		}
+	}
+
+	if db.Layout() != l {
+		t.Errorf("layout not updated")
+	}
+
+	// migrating back restores the old names and removes the empty year dirs
+	_, err = db.Migrate(MustParseLayout(DefaultLayout))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		_, err := os.Stat(filepath.Join(db.Dir, file.Correspondent, file.Filename))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(db.Dir, "Bank", "2023"))
+	if !os.IsNotExist(err) {
+		t.Errorf("empty dir not removed: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Query selects files from the database.
+type Query struct {
+	terms []queryTerm
+}
+
+type queryTerm struct {
+	key, value string
+	negate     bool
+}
+
+// ParseQuery parses a query consisting of terms separated by whitespace. All
+// terms must match a file. Supported are "tag:tax", "type:invoice",
+// "correspondent:name", "folder:name" (including subfolders), "uploader:name"
+// and plain words, which must be contained in the title or filename. Terms
+// starting with "-" must not match.
+func ParseQuery(s string) (Query, error) {
+	var q Query
+
+	for _, field := range strings.Fields(s) {
+		var term queryTerm
+
+		if strings.HasPrefix(field, "-") && len(field) > 1 {
+			term.negate = true
+			field = field[1:]
+		}
+
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			key, value = "", field
+		}
+
+		switch key {
+		case "", "tag", "type", "correspondent", "folder", "uploader":
+		default:
+			return Query{}, fmt.Errorf("unknown key %q in query", key)
+		}
+
+		term.key = key
+		term.value = strings.ToLower(value)
+
+		q.terms = append(q.terms, term)
+	}
+
+	return q, nil
+}
+
+// Matches returns true if the file matches all terms of the query.
+func (q Query) Matches(file File) bool {
+	for _, term := range q.terms {
+		if term.matches(file) == term.negate {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t queryTerm) matches(file File) bool {
+	switch t.key {
+	case "tag":
+		return file.HasTag(t.value)
+	case "type":
+		return strings.ToLower(file.Type) == t.value
+	case "correspondent":
+		return strings.ToLower(file.Correspondent) == t.value
+	case "uploader":
+		return strings.ToLower(file.UploadedBy) == t.value
+	case "folder":
+		folder := strings.ToLower(file.Folder)
+
+		return folder == t.value || strings.HasPrefix(folder, t.value+"/")
+	default:
+		return strings.Contains(strings.ToLower(file.Title), t.value) ||
+			strings.Contains(strings.ToLower(file.Filename), t.value)
+	}
+}
+
+// Query returns all files matching q.
+func (db *Database) Query(q Query) map[string]File {
+	res := make(map[string]File)
+
+	for id, file := range db.Files() {
+		if q.Matches(file) {
+			res[id] = file
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,51 @@
+package database
+
+import "testing"
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	file := File{
+		Filename:      "2023-05-02 Steuerbescheid.pdf",
+		Correspondent: "Finanzamt",
+		Title:         "Steuerbescheid",
+		Type:          "notice",
+		Tags:          []string{"2023", "tax"},
+		UploadedBy:    "alice",
+	}
+
+	tests := []struct {
+		query string
+		match bool
+	}{
+		{"", true},
+		{"tag:tax", true},
+		{"tag:TAX type:notice", true},
+		{"tag:tax -tag:paid", true},
+		{"-tag:tax", false},
+		{"tag:warranty", false},
+		{"correspondent:finanzamt steuer", true},
+		{"type:invoice", false},
+		{"bescheid -rechnung", true},
+		{"uploader:Alice", true},
+		{"-uploader:bob", true},
+	}
+
+	for _, test := range tests {
+		q, err := ParseQuery(test.query)
+		if err != nil {
+			t.Errorf("parse %q: %v", test.query, err)
+
+			continue
+		}
+
+		if q.Matches(file) != test.match {
+			t.Errorf("query %q: want match %v, got %v", test.query, test.match, !test.match)
+		}
+	}
+
+	_, err := ParseQuery("foo:bar")
+	if err == nil {
+		t.Errorf("expected error for unknown key")
+	}
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidType is returned for document types which cannot be recognized
+// in a file name created from the layout.
+var ErrInvalidType = errors.New("invalid type")
+
+// checkType returns an error if the type of f is not found again when the
+// name created by l is parsed.
+func (db *Database) checkType(l *Layout, f File, name string) error {
+	if f.Type == "" || !l.Has(FieldType) {
+		return nil
+	}
+
+	types := db.Types()
+	if !slices.Contains(types, f.Type) {
+		types = append(types, f.Type)
+	}
+
+	fields, err := l.Parse(path.Join(f.Correspondent, name), types)
+	if err != nil || fields[FieldType] != f.Type {
+		return fmt.Errorf("%w %q, it cannot be parsed from the filename %q", ErrInvalidType, f.Type, name)
+	}
+
+	return nil
+}
+
+// freeName returns the name of f according to layout l which is not used by
+// another file, the counter is appended like Migrate does.
+func (db *Database) freeName(l *Layout, current, f File) (string, error) {
+	name, err := l.Filename(f, "")
+	if err != nil {
+		return "", err
+	}
+
+	for counter := 1; ; counter++ {
+		f.Filename = name
+
+		_, err := os.Lstat(db.path(f))
+		if errors.Is(err, os.ErrNotExist) || db.path(f) == db.path(current) {
+			return name, nil
+		}
+
+		name, err = l.Filename(f, fmt.Sprintf("- %d", counter))
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// moveFile renames the file described by current to the name in f. The
+// original file is moved along and named after the new file. The metadata
+// with the name of the original is returned.
+func (db *Database) moveFile(id string, current, f File) (File, error) {
+	f.Original = current.Original
+
+	from, to := db.path(current), db.path(f)
+	if from == to {
+		return f, nil
+	}
+
+	// rename() replaces existing files, so check before
+	_, err := os.Lstat(to)
+	if err == nil {
+		return File{}, fmt.Errorf("rename to %v: %w", to, os.ErrExist)
+	}
+
+	err = os.MkdirAll(filepath.Dir(to), 0770)
+	if err != nil {
+		return File{}, fmt.Errorf("rename: %w", err)
+	}
+
+	err = os.Rename(from, to)
+	if err != nil {
+		return File{}, fmt.Errorf("rename: %w", err)
+	}
+
+	// the original file is kept next to the PDF file
+	if current.Original != "" {
+		original := strings.TrimSuffix(filepath.Base(to), ".pdf") + filepath.Ext(current.Original)
+
+		err = os.Rename(filepath.Join(filepath.Dir(from), current.Original), filepath.Join(filepath.Dir(to), original))
+		if err != nil {
+			db.log.WithField("id", id).Warnf("move original file: %v", err)
+
+			f.Original = ""
+		} else {
+			f.Original = original
+		}
+	}
+
+	return f, nil
+}
+
+// UpdateAndRename works like Update, but if a field used in the layout
+// changes, the file is renamed according to the layout. For types which
+// cannot be recognized in the new name, ErrInvalidType is returned.
+func (db *Database) UpdateAndRename(origin Origin, id string, fn func(*File)) (File, error) {
+	db.layoutMu.RLock()
+	defer db.layoutMu.RUnlock()
+
+	current, ok := db.GetFile(id)
+	if !ok {
+		return File{}, fmt.Errorf("%v: %w", id, ErrNotFound)
+	}
+
+	file := current
+	file.Tags = slices.Clone(current.Tags)
+	fn(&file)
+
+	l := db.Layout()
+
+	before, err := l.Filename(current, "")
+	if err != nil {
+		// names which cannot be built from the metadata are kept
+		return db.Update(origin, id, fn)
+	}
+
+	after, err := l.Filename(file, "")
+	if err != nil {
+		return File{}, err
+	}
+
+	if file.Type != current.Type {
+		err = db.checkType(l, file, after)
+		if err != nil {
+			return File{}, err
+		}
+	}
+
+	if before == after {
+		return db.Update(origin, id, fn)
+	}
+
+	file.Filename, err = db.freeName(l, current, file)
+	if err != nil {
+		return File{}, err
+	}
+
+	file, err = db.moveFile(id, current, file)
+	if err != nil {
+		return File{}, err
+	}
+
+	db.SetFile(origin, id, file)
+
+	return file, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateAndRename(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+	db.SetLayout(MustParseLayout("{correspondent}/{date} {type} {title}.pdf"))
+
+	err := os.MkdirAll(filepath.Join(db.Dir, "Bank"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(db.Dir, "Bank", "2023-01-02 foo.pdf")
+	write(t, filename, "foo")
+	write(t, filepath.Join(db.Dir, "Bank", "2023-01-02 foo.jpg"), "original")
+
+	id, err := FileID(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := File{
+		Correspondent: "Bank",
+		Filename:      "2023-01-02 foo.pdf",
+		Original:      "2023-01-02 foo.jpg",
+		Date:          "02.01.2023",
+		Title:         "foo",
+	}
+	db.SetFile(Origin{}, id, file)
+
+	// tags are not part of the layout, the name stays the same
+	got, err := db.UpdateAndRename(Origin{}, id, func(f *File) {
+		f.Tags = []string{"tax"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Filename != file.Filename {
+		t.Errorf("file was renamed to %q", got.Filename)
+	}
+
+	got, err = db.UpdateAndRename(Origin{}, id, func(f *File) {
+		f.Type = "invoice"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Filename != "2023-01-02 invoice foo.pdf" || got.Original != "2023-01-02 invoice foo.jpg" {
+		t.Errorf("wrong names %q and %q", got.Filename, got.Original)
+	}
+
+	for _, name := range []string{got.Filename, got.Original} {
+		_, err = os.Stat(filepath.Join(db.Dir, "Bank", name))
+		if err != nil {
+			t.Errorf("file was not renamed: %v", err)
+		}
+	}
+
+	// the watcher must find the same metadata in the new name
+	err = db.OnRename(filepath.Join(db.Dir, "Bank", got.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := db.GetFile(id)
+	if !stored.Equal(got) {
+		t.Errorf("metadata changed by the watcher, want %+v, got %+v", got, stored)
+	}
+
+	_, err = db.UpdateAndRename(Origin{}, id, func(f *File) {
+		f.Type = "invoice!"
+	})
+	if !errors.Is(err, ErrInvalidType) {
+		t.Errorf("expected ErrInvalidType, got %v", err)
+	}
+}
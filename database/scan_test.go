@@ -0,0 +1,61 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanNested(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+
+	for _, name := range []string{
+		"Bank/2023-01-02 Kontoauszug.pdf",
+		"Bank/Kredit/2019/2019-03-04 Vertrag.pdf",
+		"Bank/2024/notes.pdf",
+	} {
+		filename := filepath.Join(db.Dir, filepath.FromSlash(name))
+
+		err := os.MkdirAll(filepath.Dir(filename), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		write(t, filename, name)
+	}
+
+	err := db.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]File{
+		"Bank/2023-01-02 Kontoauszug.pdf": {Correspondent: "Bank", Date: "02.01.2023", Title: "Kontoauszug"},
+		"Bank/Kredit/2019/2019-03-04 Vertrag.pdf": {Correspondent: "Bank", Date: "04.03.2019", Title: "Vertrag",
+			Folder: "Kredit/2019"},
+		"Bank/2024/notes.pdf": {Correspondent: "Bank", Title: "notes", Folder: "2024"},
+	}
+
+	files := db.Files()
+	if len(files) != len(want) {
+		t.Fatalf("want %d files, got %v", len(want), files)
+	}
+
+	for _, file := range files {
+		name := file.Correspondent + "/" + file.Filename
+
+		w, ok := want[name]
+		if !ok {
+			t.Errorf("unexpected file %v", name)
+
+			continue
+		}
+
+		file.Filename = ""
+		if !file.Equal(w) {
+			t.Errorf("%v: want %v (folder %q), got %v (folder %q)", name, w, w.Folder, file, file.Folder)
+		}
+	}
+}
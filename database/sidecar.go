@@ -0,0 +1,162 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sidecar is the data stored in a sidecar file next to each file in the
+// archive. The filename, correspondent and folder are taken from the location.
+type sidecar struct {
+	ID                string   `json:"id"`
+	Date              string   `json:"date,omitempty"`
+	Title             string   `json:"title,omitempty"`
+	Language          string   `json:"language,omitempty"`
+	Type              string   `json:"type,omitempty"`
+	Tags              []string `json:"tags,omitempty"`
+	BornDigital       bool     `json:"born_digital,omitempty"`
+	Original          string   `json:"original,omitempty"`
+	BlankPagesRemoved int      `json:"blank_pages_removed,omitempty"`
+	DueDate           string   `json:"due_date,omitempty"`
+	Amount            string   `json:"amount,omitempty"`
+	UploadedBy        string   `json:"uploaded_by,omitempty"`
+}
+
+// SidecarFilename returns the name of the sidecar file for filename, which is
+// a hidden file in the same directory, e.g. ".2023-01-02 foo.pdf.json".
+func SidecarFilename(filename string) string {
+	return filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".json")
+}
+
+// ThumbnailDir is the directory below the archive with the thumbnails of the
+// files, named after the file ID.
+const ThumbnailDir = ".nepomuk/thumbnails"
+
+// ThumbnailFilename returns the location of the thumbnail for file id. The
+// thumbnail may not exist.
+func (db *Database) ThumbnailFilename(id string) string {
+	return filepath.Join(db.Dir, ThumbnailDir, id+".jpg")
+}
+
+// removeThumbnail removes the thumbnail for a file which is gone for good.
+func (db *Database) removeThumbnail(id string) {
+	err := os.Remove(db.ThumbnailFilename(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		db.log.WithField("id", id).Warnf("remove thumbnail: %v", err)
+	}
+}
+
+// path returns the location of f in the archive.
+func (db *Database) path(f File) string {
+	return filepath.Join(db.Dir, f.Correspondent, filepath.FromSlash(f.Filename))
+}
+
+// writeSidecar writes the sidecar file for f.
+func (db *Database) writeSidecar(id string, f File) error {
+	buf, err := json.MarshalIndent(sidecar{
+		ID:                id,
+		Date:              f.Date,
+		Title:             f.Title,
+		Language:          f.Language,
+		Type:              f.Type,
+		Tags:              f.Tags,
+		BornDigital:       f.BornDigital,
+		Original:          f.Original,
+		BlankPagesRemoved: f.BlankPagesRemoved,
+		DueDate:           f.DueDate,
+		Amount:            f.Amount,
+		UploadedBy:        f.UploadedBy,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode sidecar: %w", err)
+	}
+
+	filename := SidecarFilename(db.path(f))
+
+	// write to a temporary file first so the sidecar is never incomplete
+	err = os.WriteFile(filename+".tmp", append(buf, '\n'), 0640)
+	if err != nil {
+		return fmt.Errorf("write sidecar: %w", err)
+	}
+
+	err = os.Rename(filename+".tmp", filename)
+	if err != nil {
+		return fmt.Errorf("write sidecar: %w", err)
+	}
+
+	return nil
+}
+
+// syncSidecar updates the sidecar files after the metadata for id changed
+// from oldFile to newFile. An empty Filename means there is no file.
+func (db *Database) syncSidecar(id string, oldFile, newFile File) {
+	if !db.Sidecars {
+		return
+	}
+
+	log := db.log.WithField("id", id)
+
+	if oldFile.Filename != "" && (newFile.Filename == "" || db.path(oldFile) != db.path(newFile)) {
+		err := os.Remove(SidecarFilename(db.path(oldFile)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("remove sidecar: %v", err)
+		}
+	}
+
+	if newFile.Filename == "" {
+		return
+	}
+
+	// the file may not have been moved to its location yet
+	_, err := os.Lstat(db.path(newFile))
+	if err != nil {
+		log.Debugf("not writing sidecar: %v", err)
+
+		return
+	}
+
+	err = db.writeSidecar(id, newFile)
+	if err != nil {
+		log.Warnf("%v", err)
+	}
+}
+
+// readSidecar returns the metadata from the sidecar file for filename
+// together with the ID recorded in it. If there is no sidecar file, false is
+// returned.
+func readSidecar(filename string) (File, string, bool, error) {
+	buf, err := os.ReadFile(SidecarFilename(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return File{}, "", false, nil
+	}
+
+	if err != nil {
+		return File{}, "", false, fmt.Errorf("read sidecar: %w", err)
+	}
+
+	var sc sidecar
+
+	err = json.Unmarshal(buf, &sc)
+	if err != nil {
+		return File{}, "", false, fmt.Errorf("decode sidecar %v: %w", SidecarFilename(filename), err)
+	}
+
+	f := File{
+		Date:              sc.Date,
+		Title:             sc.Title,
+		Language:          sc.Language,
+		Type:              sc.Type,
+		Tags:              NormalizeTags(sc.Tags),
+		BornDigital:       sc.BornDigital,
+		Original:          sc.Original,
+		BlankPagesRemoved: sc.BlankPagesRemoved,
+		DueDate:           sc.DueDate,
+		Amount:            sc.Amount,
+		UploadedBy:        sc.UploadedBy,
+	}
+
+	return f, sc.ID, true, nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSidecar(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+	db.Sidecars = true
+
+	err := os.MkdirAll(filepath.Join(db.Dir, "Bank"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(db.Dir, "Bank", "2023-01-02 foo.pdf")
+	write(t, filename, "foo")
+
+	id, err := FileID(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := File{
+		Correspondent: "Bank",
+		Filename:      "2023-01-02 foo.pdf",
+		Date:          "02.01.2023",
+		Title:         "foo",
+		Type:          "statement",
+		Tags:          []string{"tax"},
+	}
+	db.SetFile(Origin{}, id, file)
+
+	_, err = os.Stat(SidecarFilename(filename))
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+
+	// rename the file, the sidecar follows
+	newFilename := filepath.Join(db.Dir, "Bank", "2023-01-02 bar.pdf")
+	rename(t, filename, newFilename)
+
+	err = db.OnRename(newFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(SidecarFilename(filename))
+	if !os.IsNotExist(err) {
+		t.Errorf("old sidecar still exists: %v", err)
+	}
+
+	// rebuild the database from the sidecar
+	db2 := New(db.Dir)
+	db2.Sidecars = true
+
+	err = db2.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := db2.GetFile(id)
+	if !ok {
+		t.Fatalf("file not found after scan")
+	}
+
+	file.Filename = "2023-01-02 bar.pdf"
+	file.Title = "bar"
+
+	if !reflect.DeepEqual(got, file) {
+		t.Errorf("want %+v, got %+v", file, got)
+	}
+
+	// deleting the file removes the sidecar
+	err = os.Remove(newFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.OnDelete(newFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(SidecarFilename(newFilename))
+	if !os.IsNotExist(err) {
+		t.Errorf("sidecar still exists: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// TagsDir is the directory within the archive which mirrors the tags as symlinks.
+const TagsDir = "tags"
+
+// NormalizeTags returns the sorted list of tags in lower case without
+// duplicates. Empty tags and tags containing a slash are dropped.
+func NormalizeTags(tags []string) []string {
+	var res []string
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "." || tag == ".." || strings.Contains(tag, "/") {
+			continue
+		}
+
+		res = append(res, tag)
+	}
+
+	slices.Sort(res)
+
+	return slices.Compact(res)
+}
+
+// HasTag returns true if the file is tagged with tag.
+func (f File) HasTag(tag string) bool {
+	return slices.Contains(f.Tags, strings.ToLower(tag))
+}
+
+// tagLinks returns the symlinks for the tags of files, mapping the link
+// (relative to TagsDir) to the target.
+func tagLinks(files map[string]File) map[string]string {
+	links := make(map[string]string)
+
+	for _, file := range files {
+		for _, tag := range file.Tags {
+			name := strings.ReplaceAll(file.Filename, "/", " - ")
+			link := filepath.Join(tag, file.Correspondent+" - "+name)
+			links[link] = filepath.Join("..", "..", file.Correspondent, file.Filename)
+		}
+	}
+
+	return links
+}
+
+// SyncTagLinks updates the directory TagsDir within the archive so that it
+// contains a subdir for each tag with symlinks to the tagged files.
+func (db *Database) SyncTagLinks() error {
+	db.linksMu.Lock()
+	defer db.linksMu.Unlock()
+
+	dir := filepath.Join(db.Dir, TagsDir)
+	links := tagLinks(db.Files())
+
+	// remove outdated links and empty tag directories
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if entry.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("readlink: %w", err)
+		}
+
+		if links[rel] == target {
+			delete(links, rel)
+
+			return nil
+		}
+
+		db.log.Debugf("remove tag link %v", rel)
+
+		return os.Remove(path)
+	})
+	if err != nil {
+		return fmt.Errorf("sync tag links: %w", err)
+	}
+
+	for link, target := range links {
+		path := filepath.Join(dir, link)
+
+		err := os.MkdirAll(filepath.Dir(path), 0770)
+		if err != nil {
+			return fmt.Errorf("create tag dir: %w", err)
+		}
+
+		err = os.Symlink(target, path)
+		if err != nil {
+			return fmt.Errorf("create tag link: %w", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("readdir %v: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// only succeeds for empty directories
+		_ = os.Remove(filepath.Join(dir, entry.Name()))
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	t.Parallel()
+
+	got := NormalizeTags([]string{"Tax", " warranty", "tax", "", "a/b", "insurance"})
+	want := []string{"insurance", "tax", "warranty"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestSyncTagLinks(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+	db.SetFile(Origin{}, "1", File{Filename: "2023-01-02 foo.pdf", Correspondent: "bank", Tags: []string{"tax", "paid"}})
+	db.SetFile(Origin{}, "2", File{Filename: "2023-02-03 bar.pdf", Correspondent: "shop", Tags: []string{"warranty"}})
+
+	err := db.SyncTagLinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(db.Dir, TagsDir, "tax", "bank - 2023-01-02 foo.pdf")
+
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target != "../../bank/2023-01-02 foo.pdf" {
+		t.Errorf("wrong target %v", target)
+	}
+
+	// removing a tag removes the link and the empty directory
+	db.SetFile(Origin{}, "2", File{Filename: "2023-02-03 bar.pdf", Correspondent: "shop"})
+
+	err = db.SyncTagLinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Lstat(filepath.Join(db.Dir, TagsDir, "warranty"))
+	if !os.IsNotExist(err) {
+		t.Errorf("tag dir warranty still exists: %v", err)
+	}
+
+	_, err = os.Lstat(link)
+	if err != nil {
+		t.Errorf("link for tag tax was removed: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TrashDir is the directory below the archive where deleted files are kept.
+const TrashDir = ".nepomuk/trash"
+
+// Tombstone keeps the metadata of a deleted file, so it can be restored.
+type Tombstone struct {
+	File    File      `yaml:"file"`
+	Deleted time.Time `yaml:"deleted"`
+
+	// Trashed is set if the file was moved to the trash directory, otherwise
+	// only the metadata is kept (e.g. after the file was removed by the user).
+	Trashed bool `yaml:"trashed"`
+}
+
+// ErrTrashDisabled is returned when files are removed with the trash disabled.
+var ErrTrashDisabled = errors.New("trash is not enabled")
+
+// ErrNotTrashed is returned by Restore for files which were not moved to the
+// trash. They can be restored by copying them back into the archive.
+var ErrNotTrashed = errors.New("file is not in the trash, copy it back into the archive to restore it")
+
+// trashPath returns the location of name for file id in the trash.
+func (db *Database) trashPath(id, name string) string {
+	return filepath.Join(db.Dir, TrashDir, id, filepath.Base(name))
+}
+
+// Trash returns a copy of all tombstones.
+func (db *Database) Trash() map[string]Tombstone {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res := make(map[string]Tombstone, len(db.Tombstones))
+	for id, tomb := range db.Tombstones {
+		res[id] = tomb
+	}
+
+	return res
+}
+
+// tombstone returns the tombstone for id.
+func (db *Database) tombstone(id string) (Tombstone, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tomb, ok := db.Tombstones[id]
+
+	return tomb, ok
+}
+
+// Remove moves the file id to the trash directory and removes it from the
+// database. The metadata is kept until the file is purged.
+func (db *Database) Remove(origin Origin, id string) error {
+	if !db.SoftDelete {
+		return ErrTrashDisabled
+	}
+
+	db.layoutMu.RLock()
+	defer db.layoutMu.RUnlock()
+
+	file, ok := db.GetFile(id)
+	if !ok {
+		return fmt.Errorf("%v: %w", id, ErrNotFound)
+	}
+
+	err := os.MkdirAll(filepath.Join(db.Dir, TrashDir, id), 0700)
+	if err != nil {
+		return fmt.Errorf("create trash dir: %w", err)
+	}
+
+	err = os.Rename(db.path(file), db.trashPath(id, file.Filename))
+	if err != nil {
+		return fmt.Errorf("move to trash: %w", err)
+	}
+
+	// the original file is kept next to the PDF file
+	if file.Original != "" {
+		err = os.Rename(filepath.Join(filepath.Dir(db.path(file)), file.Original), db.trashPath(id, file.Original))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			db.log.WithField("id", id).Warnf("move original file to trash: %v", err)
+		}
+	}
+
+	db.delete(origin, id, true)
+
+	return nil
+}
+
+// Restore moves the file id from the trash back to its old location in the
+// archive and restores the metadata.
+func (db *Database) Restore(origin Origin, id string) (File, error) {
+	db.layoutMu.RLock()
+	defer db.layoutMu.RUnlock()
+
+	tomb, ok := db.tombstone(id)
+	if !ok {
+		return File{}, fmt.Errorf("%v: %w", id, ErrNotFound)
+	}
+
+	if !tomb.Trashed {
+		return File{}, fmt.Errorf("%v: %w", id, ErrNotTrashed)
+	}
+
+	file := tomb.File
+	target := db.path(file)
+
+	_, err := os.Lstat(target)
+	if err == nil {
+		return File{}, fmt.Errorf("restore %v: %w", target, os.ErrExist)
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0770)
+	if err != nil {
+		return File{}, fmt.Errorf("restore: %w", err)
+	}
+
+	// move the original first, the watcher may remove the trash dir as soon
+	// as the PDF file is back in the archive
+	if file.Original != "" {
+		err = os.Rename(db.trashPath(id, file.Original), filepath.Join(filepath.Dir(target), file.Original))
+		if err != nil {
+			db.log.WithField("id", id).Warnf("restore original file: %v", err)
+
+			file.Original = ""
+		}
+	}
+
+	err = os.Rename(db.trashPath(id, file.Filename), target)
+	if err != nil {
+		return File{}, fmt.Errorf("restore: %w", err)
+	}
+
+	db.SetFile(origin, id, file)
+
+	return file, nil
+}
+
+// Purge removes the file id from the trash, together with its metadata.
+func (db *Database) Purge(id string) error {
+	db.mu.Lock()
+	_, ok := db.Tombstones[id]
+	delete(db.Tombstones, id)
+	db.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%v: %w", id, ErrNotFound)
+	}
+
+	db.removeThumbnail(id)
+
+	err := os.RemoveAll(filepath.Join(db.Dir, TrashDir, id))
+	if err != nil {
+		return fmt.Errorf("purge %v: %w", id, err)
+	}
+
+	return nil
+}
+
+// PurgeExpired removes all files which were deleted longer than the
+// retention period ago. It returns the number of purged files.
+func (db *Database) PurgeExpired(now time.Time) (int, error) {
+	if db.Retention <= 0 {
+		return 0, nil
+	}
+
+	var (
+		n        int
+		firstErr error
+	)
+
+	for id, tomb := range db.Trash() {
+		if now.Sub(tomb.Deleted) < db.Retention {
+			continue
+		}
+
+		db.log.WithField("id", id).Infof("purge %v/%v from trash", tomb.File.Correspondent, tomb.File.Filename)
+
+		err := db.Purge(id)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		n++
+	}
+
+	return n, firstErr
+}
+
+// removeFromTrash removes the tombstone for id after the file is back in the
+// archive.
+func (db *Database) removeFromTrash(id string) {
+	db.mu.Lock()
+	tomb, ok := db.Tombstones[id]
+	delete(db.Tombstones, id)
+	db.mu.Unlock()
+
+	if !ok || !tomb.Trashed {
+		return
+	}
+
+	// the file in the trash is a copy of the file in the archive
+	err := os.RemoveAll(filepath.Join(db.Dir, TrashDir, id))
+	if err != nil {
+		db.log.WithField("id", id).Warnf("remove file from trash: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	t.Parallel()
+
+	db := New(t.TempDir())
+	db.SoftDelete = true
+	db.Retention = 24 * time.Hour
+
+	err := os.MkdirAll(filepath.Join(db.Dir, "Bank"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(db.Dir, "Bank", "2023-01-02 foo.pdf")
+	write(t, filename, "foo")
+
+	id, err := FileID(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := File{
+		Correspondent: "Bank",
+		Filename:      "2023-01-02 foo.pdf",
+		Date:          "02.01.2023",
+		Title:         "foo",
+		Tags:          []string{"tax"},
+	}
+	db.SetFile(Origin{}, id, file)
+
+	// move to the trash and restore
+	err = db.Remove(Origin{}, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filename)
+	if !os.IsNotExist(err) {
+		t.Fatalf("file still exists: %v", err)
+	}
+
+	if _, ok := db.GetFile(id); ok {
+		t.Fatalf("file still in database")
+	}
+
+	got, err := db.Restore(Origin{}, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Equal(file) {
+		t.Errorf("want %+v, got %+v", file, got)
+	}
+
+	_, err = os.Stat(filename)
+	if err != nil {
+		t.Fatalf("file was not restored: %v", err)
+	}
+
+	if len(db.Trash()) != 0 {
+		t.Errorf("trash not empty after restore: %v", db.Trash())
+	}
+
+	// delete the file, copy it back with a different name
+	err = os.Remove(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.OnDelete(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Restore(Origin{}, id)
+	if !errors.Is(err, ErrNotTrashed) {
+		t.Errorf("expected ErrNotTrashed, got %v", err)
+	}
+
+	newFilename := filepath.Join(db.Dir, "Bank", "2023-01-02 bar.pdf")
+	write(t, newFilename, "foo")
+
+	err = db.OnRename(newFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := db.GetFile(id)
+	if !ok {
+		t.Fatalf("file not found")
+	}
+
+	file.Filename = "2023-01-02 bar.pdf"
+	file.Title = "bar"
+
+	if !got.Equal(file) {
+		t.Errorf("want %+v, got %+v", file, got)
+	}
+
+	// purge after the retention period
+	err = db.Remove(Origin{}, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := db.PurgeExpired(time.Now())
+	if err != nil || n != 0 {
+		t.Fatalf("purged %d files too early: %v", n, err)
+	}
+
+	n, err = db.PurgeExpired(time.Now().Add(25 * time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("expected one purged file, got %d: %v", n, err)
+	}
+
+	_, err = os.Stat(filepath.Join(db.Dir, TrashDir, id))
+	if !os.IsNotExist(err) {
+		t.Errorf("trash dir still exists: %v", err)
+	}
+}
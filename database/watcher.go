@@ -1,11 +1,28 @@
 package database
 
 import (
+	"path/filepath"
+	"slices"
+	"strings"
+
 	"github.com/sirupsen/logrus"
 )
 
 const defaultInotifyChanBuf = 200
 
+// skipDirs are the directories within the archive which do not contain
+// archived files: internal data, files which are not (yet) part of the
+// archive and the tag links.
+var skipDirs = []string{".nepomuk", "incoming", "failed", TagsDir}
+
+// skipPath returns true if name, relative to the archive directory, is within
+// one of the skipDirs.
+func skipPath(name string) bool {
+	dir, _, _ := strings.Cut(filepath.ToSlash(name), "/")
+
+	return slices.Contains(skipDirs, dir)
+}
+
 // Watcher keeps track of file renames.
 type Watcher struct {
 	ArchiveDir string
@@ -1,3 +1,5 @@
+//go:build cgo && !kqueue
+
 package database
 
 import (
@@ -6,7 +8,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/rjeczalik/notify"
 )
@@ -30,9 +31,6 @@ func (w *Watcher) Run(ctx context.Context) error {
 		return fmt.Errorf("unable to find absolute dir: %w", err)
 	}
 
-	absInternalPath := filepath.Clean(filepath.Join(abspath, ".nepomuk")) + "/"
-	absIncomingPath := filepath.Clean(filepath.Join(abspath, "incoming")) + "/"
-
 	ch := make(chan notify.EventInfo, defaultInotifyChanBuf)
 
 	// recursively watch for events fired when files are moved or renamed
@@ -63,18 +61,14 @@ outer:
 				w.log.Warnf("received event is not *unix.FSEvent but %T: %v", evinfo, evinfo)
 			}
 
-			// ignore events in an internal path or incoming
-			if strings.HasPrefix(evinfo.Path(), absInternalPath) || strings.HasPrefix(evinfo.Path(), absIncomingPath) {
+			// ignore events in an internal path, incoming, failed or the tag links
+			rel, err := filepath.Rel(abspath, evinfo.Path())
+			if err != nil || skipPath(rel) {
 				w.log.Debugf("ignore event for path %v", evinfo.Path())
 
 				continue
 			}
 
-			// ignore events in incoming/, will be processed by the extracter
-			if filepath.Base(filepath.Dir(evinfo.Path())) == "incoming" {
-				continue
-			}
-
 			// ignore events on non-files
 			if ev.Flags&notify.FSEventsIsFile == 0 {
 				continue
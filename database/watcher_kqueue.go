@@ -0,0 +1,87 @@
+//go:build darwin && (kqueue || !cgo)
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rjeczalik/notify"
+)
+
+// watchDir uses kqueue, the FSEvents backend in watcher_darwin.go requires cgo.
+func watchDir(dirname string, ch chan<- notify.EventInfo) error {
+	return notify.Watch(
+		dirname,
+		ch,
+		notify.Create, notify.Remove, notify.Rename)
+}
+
+// Run starts a process which watches archiveDir for renames and deletions and
+// provides a callback for such files.
+func (w *Watcher) Run(ctx context.Context) error {
+	abspath, err := filepath.Abs(w.ArchiveDir)
+	if err != nil {
+		return fmt.Errorf("unable to find absolute dir: %w", err)
+	}
+
+	ch := make(chan notify.EventInfo, defaultInotifyChanBuf)
+
+	// recursively watch for events fired when files are moved or renamed
+	err = watchDir(filepath.Join(w.ArchiveDir, "..."), ch)
+	if err != nil {
+		return fmt.Errorf("kqueue watch failed: %w", err)
+	}
+
+	if w.OnStartWatching != nil {
+		w.log.Debug("run hook OnStartWatching")
+		w.OnStartWatching()
+	}
+
+	w.log.Debugf("watch files in %v", w.ArchiveDir)
+
+outer:
+	for {
+		select {
+		case <-ctx.Done():
+			break outer
+		case evinfo, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			// ignore events in an internal path, incoming, failed or the tag links
+			rel, err := filepath.Rel(abspath, evinfo.Path())
+			if err != nil || skipPath(rel) {
+				w.log.Debugf("ignore event for path %v", evinfo.Path())
+
+				continue
+			}
+
+			switch evinfo.Event() {
+			case notify.Remove:
+				w.log.Debugf("remove detected for %v", evinfo.Path())
+				w.OnFileDeleted(evinfo.Path())
+
+			case notify.Create, notify.Rename:
+				// kqueue reports renames for the old name, so only files which
+				// exist are passed on
+				fi, err := os.Lstat(evinfo.Path())
+				if errors.Is(err, os.ErrNotExist) || (err == nil && !fi.Mode().IsRegular()) {
+					continue
+				}
+
+				w.log.Debugf("rename detected, new name: %v", evinfo.Path())
+				w.OnFileRenamed(evinfo.Path())
+
+			default:
+				w.log.Warnf("unknown event %#v received: %#v", evinfo.Event(), evinfo.Sys())
+			}
+		}
+	}
+
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
-	"strings"
 
 	"github.com/rjeczalik/notify"
 )
@@ -24,9 +23,6 @@ func (w *Watcher) Run(ctx context.Context) error {
 		return fmt.Errorf("unable to find absolute dir: %w", err)
 	}
 
-	absInternalPath := filepath.Clean(filepath.Join(abspath, ".nepomuk")) + "/"
-	absIncomingPath := filepath.Clean(filepath.Join(abspath, "incoming")) + "/"
-
 	ch := make(chan notify.EventInfo, defaultInotifyChanBuf)
 
 	// recursively watch for events fired when files are moved or renamed
@@ -52,8 +48,9 @@ outer:
 				return nil
 			}
 
-			// ignore events in an internal path or incoming
-			if strings.HasPrefix(evinfo.Path(), absInternalPath) || strings.HasPrefix(evinfo.Path(), absIncomingPath) {
+			// ignore events in an internal path, incoming, failed or the tag links
+			rel, err := filepath.Rel(abspath, evinfo.Path())
+			if err != nil || skipPath(rel) {
 				w.log.Debugf("ignore event for path %v", evinfo.Path())
 
 				continue
@@ -61,11 +58,6 @@ outer:
 
 			w.log.Debugf("event for path %v", evinfo.Path())
 
-			// ignore events in incoming/, will be processed by the extracter
-			if filepath.Base(filepath.Dir(evinfo.Path())) == "incoming" {
-				continue
-			}
-
 			// keep state until we have collected both events
 			switch evinfo.Event() {
 			case notify.InDelete:
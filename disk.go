@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fd0/nepomuk/notify"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// diskFree returns the free space on the file system containing dir, in bytes
+// and relative to its size.
+func diskFree(dir string) (uint64, float64, error) {
+	var st unix.Statfs_t
+
+	err := unix.Statfs(dir, &st)
+	if err != nil {
+		return 0, 0, fmt.Errorf("statfs %v: %w", dir, err)
+	}
+
+	if st.Blocks == 0 {
+		return 0, 100, nil
+	}
+
+	free := uint64(st.Bavail) * uint64(st.Bsize)
+	percent := float64(st.Bavail) / float64(st.Blocks) * 100
+
+	return free, percent, nil
+}
+
+// watchDiskSpace checks the free space of dir regularly until ctx is
+// cancelled, a notification is sent once when it drops below minPercent.
+func watchDiskSpace(ctx context.Context, log logrus.FieldLogger, dir string, minPercent float64, notifier *notify.Dispatcher) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	notified := false
+
+	for {
+		free, percent, err := diskFree(dir)
+
+		switch {
+		case err != nil:
+			log.Warnf("%v", err)
+		case percent < minPercent && !notified:
+			log.Warnf("only %.1f%% free on disk for %v", percent, dir)
+
+			_ = notifier.Event(ctx, notify.Event{
+				Kind:            notify.EventDiskFull,
+				Path:            dir,
+				DiskFree:        free,
+				DiskFreePercent: percent,
+			})
+
+			notified = true
+		case percent >= minPercent:
+			notified = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
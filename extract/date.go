@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -13,6 +14,55 @@ var (
 	filnenameDateRegexp = regexp.MustCompile(`^((19|20)\d{6})\-`)
 )
 
+// monthNames maps the month names for a language (tesseract language code) to the month.
+var monthNames = map[string]map[string]time.Month{
+	"deu": {
+		"januar": time.January, "jänner": time.January, "februar": time.February, "märz": time.March,
+		"april": time.April, "mai": time.May, "juni": time.June, "juli": time.July,
+		"august": time.August, "september": time.September, "oktober": time.October,
+		"november": time.November, "dezember": time.December,
+	},
+	"eng": {
+		"january": time.January, "february": time.February, "march": time.March,
+		"april": time.April, "may": time.May, "june": time.June, "july": time.July,
+		"august": time.August, "september": time.September, "october": time.October,
+		"november": time.November, "december": time.December,
+	},
+	"fra": {
+		"janvier": time.January, "février": time.February, "mars": time.March,
+		"avril": time.April, "mai": time.May, "juin": time.June, "juillet": time.July,
+		"août": time.August, "septembre": time.September, "octobre": time.October,
+		"novembre": time.November, "décembre": time.December,
+	},
+}
+
+// textDateRegexps contains the regular expressions matching dates with month
+// names for each language: the first one matches the day first (e.g.
+// "2. März 2024"), the second one the month first (e.g. "March 2, 2024").
+var textDateRegexps = func() map[string][2]*regexp.Regexp {
+	res := make(map[string][2]*regexp.Regexp, len(monthNames))
+
+	for lang, names := range monthNames {
+		list := make([]string, 0, len(names))
+		for name := range names {
+			list = append(list, regexp.QuoteMeta(name))
+		}
+
+		months := "(" + strings.Join(list, "|") + ")"
+
+		var r [2]*regexp.Regexp
+
+		r[0] = regexp.MustCompile(`(?i)\b([0123]?[0-9])\.?\s+` + months + `\s+((?:19|20)\d{2})\b`)
+		if lang == "eng" {
+			r[1] = regexp.MustCompile(`(?i)\b` + months + `\s+([0123]?[0-9]),?\s+((?:19|20)\d{2})\b`)
+		}
+
+		res[lang] = r
+	}
+
+	return res
+}()
+
 // reformatDate parses the date in s according to format and returns the standard format DD.MM.YYYY.
 func reformatDate(s, format string) (string, error) {
 	d, err := time.Parse(format, s)
@@ -23,9 +73,46 @@ func reformatDate(s, format string) (string, error) {
 	return d.Format("02.01.2006"), nil
 }
 
+// textDate returns the first date with a month name in lang found in text.
+func textDate(text []byte, lang string) (string, bool) {
+	r, ok := textDateRegexps[lang]
+	if !ok {
+		return "", false
+	}
+
+	dayFirst, monthFirst := r[0], r[1]
+
+	var day, month, year string
+
+	dm := dayFirst.FindSubmatchIndex(text)
+	if dm != nil {
+		day, month, year = string(text[dm[2]:dm[3]]), string(text[dm[4]:dm[5]]), string(text[dm[6]:dm[7]])
+	}
+
+	if monthFirst != nil {
+		// use whichever date comes first in the text
+		md := monthFirst.FindSubmatchIndex(text)
+		if md != nil && (dm == nil || md[0] < dm[0]) {
+			month, day, year = string(text[md[2]:md[3]]), string(text[md[4]:md[5]]), string(text[md[6]:md[7]])
+		}
+	}
+
+	if month == "" {
+		return "", false
+	}
+
+	s, err := reformatDate(fmt.Sprintf("%s.%02d.%s", day, monthNames[lang][strings.ToLower(month)], year), "2.01.2006")
+	if err != nil {
+		return "", false
+	}
+
+	return s, true
+}
+
 // Date returns the first date found in the text, if that fails it tries to
-// extract the date from filename.
-func Date(filename string, text []byte) (string, error) {
+// extract the date from filename. The language lang (a tesseract language
+// code, may be empty) is used to also recognize dates with month names.
+func Date(filename string, text []byte, lang string) (string, error) {
 	date := dateRegexp.Find(text)
 	if date != nil {
 		s, err := reformatDate(string(date), "02.01.2006")
@@ -36,6 +123,10 @@ func Date(filename string, text []byte) (string, error) {
 		return s, nil
 	}
 
+	if s, ok := textDate(text, lang); ok {
+		return s, nil
+	}
+
 	// try to extract date from filename
 	matches := filnenameDateRegexp.FindStringSubmatch(filepath.Base(filename))
 	if matches != nil {
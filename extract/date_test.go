@@ -0,0 +1,43 @@
+package extract
+
+import "testing"
+
+func TestDate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		filename string
+		text     string
+		lang     string
+		date     string
+	}{
+		{"foo.pdf", "Rechnung vom 03.02.2021, fällig am 17.02.2021", "deu", "03.02.2021"},
+		{"foo.pdf", "Berlin, den 3. März 2021", "deu", "03.03.2021"},
+		{"foo.pdf", "Berlin, den 3. März 2021", "", ""},
+		{"foo.pdf", "Invoice date: March 14, 2022", "eng", "14.03.2022"},
+		{"foo.pdf", "Invoice date: 14 March 2022", "eng", "14.03.2022"},
+		{"foo.pdf", "Paris, le 1 août 2020", "fra", "01.08.2020"},
+		{"20220301-120000_000000.pdf", "no date here", "deu", "01.03.2022"},
+	}
+
+	for _, test := range tests {
+		date, err := Date(test.filename, []byte(test.text), test.lang)
+		if test.date == "" {
+			if err == nil {
+				t.Errorf("Date(%q, %q): expected error, got %v", test.text, test.lang, date)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Date(%q, %q): %v", test.text, test.lang, err)
+
+			continue
+		}
+
+		if date != test.date {
+			t.Errorf("Date(%q, %q): want %v, got %v", test.text, test.lang, test.date, date)
+		}
+	}
+}
@@ -1,14 +1,16 @@
 package extract
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fd0/nepomuk/database"
+	"github.com/fd0/nepomuk/queue"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
@@ -23,10 +25,33 @@ type Extracter struct {
 
 	log logrus.FieldLogger
 
+	// mu serializes moving files into the archive so that concurrent calls
+	// to ProcessFile do not pick the same filename
+	mu sync.Mutex
+
 	Correspondents []Correspondent
 
+	// Rules assign a document type and tags to files.
+	Rules []Rule
+
+	// UploaderCorrespondents maps the user who uploaded a file to the
+	// correspondent used if none is found in the text.
+	UploaderCorrespondents map[string]string
+
+	// EmbedMetadata writes the metadata into the PDF file before it is moved
+	// into the archive. Born-digital files are not modified.
+	EmbedMetadata bool
+
 	// OnNewFile is called when a new file is found
-	OnNewFile func(database.File)
+	OnNewFile func(id string, file database.File)
+
+	// OnDuplicate is called for new files which are already in the archive,
+	// the new copy is removed.
+	OnDuplicate func(id string, existing, file database.File)
+
+	// OnReview is called for new files with metadata which could not be
+	// determined and should be checked by the user.
+	OnReview func(id string, file database.File, reason string)
 }
 
 const (
@@ -39,129 +64,300 @@ func (s *Extracter) SetLogger(logger logrus.FieldLogger) {
 	s.log = logger.WithField("component", "extracter")
 }
 
-func (s *Extracter) processFile(filename string) error {
-	id, err := database.FileID(filename)
-	if err != nil {
-		return fmt.Errorf("ID for %v failed: %w", filename, err)
-	}
+// ProcessFile extracts the data from the file of job, moves it into the
+// archive and updates the database. The metadata collected while processing
+// the file is used as a starting point. Files which are already in the archive
+// are removed.
+func (s *Extracter) ProcessFile(job *queue.Job) error {
+	filename := job.Filename
+	file := job.File
+
+	log := s.log.WithField("filename", filename)
 
-	log := s.log.WithField("filename", filename).WithField("id", id)
+	// the processed file is named after the job, followed by the name of the
+	// source file
+	name := strings.TrimPrefix(filepath.Base(filename), job.ID+"-")
 
 	text, err := Text(filename)
 	if err != nil {
 		return fmt.Errorf("extract text from %v failed: %w", filename, err)
 	}
 
-	file := database.File{
-		Title: strings.TrimRight(filepath.Base(filename), ".pdf"),
+	// correspondent and title may already be set, e.g. from a QR code
+	if file.Title == "" {
+		file.Title = strings.TrimSuffix(name, ".pdf")
 	}
 
-	file.Correspondent, err = FindCorrespondent(s.Correspondents, text)
-
-	if err != nil {
-		log.Info(err)
+	if file.Correspondent == "" {
+		file.Correspondent, err = FindCorrespondent(s.Correspondents, text)
+		if err != nil {
+			log.Info(err)
 
-		file.Correspondent = ""
+			file.Correspondent = s.UploaderCorrespondents[file.UploadedBy]
+		}
 	}
 
-	file.Date, err = Date(filename, text)
-	if err != nil {
-		log.Infof("find date failed: %v, using today", err)
+	ApplyRules(s.Rules, &file, text)
 
-		// use today's date for now
-		file.Date = time.Now().Format("02.01.2006")
+	var review []string
+
+	if file.Date == "" {
+		file.Date, err = Date(name, text, file.Language)
+		if err != nil {
+			log.Infof("find date failed: %v, using today", err)
+
+			review = append(review, "no date found")
+
+			// use today's date for now
+			file.Date = time.Now().Format("02.01.2006")
+		}
 	}
 
 	log.WithField("data", file).Print("found data")
 
-	// try to find a unique name, just in case the file at the location already exists
-	for counter := 0; ; counter++ {
-		rnd := ""
-		if counter != 0 {
-			rnd = fmt.Sprintf("- %d", counter)
-		}
+	// archived is the file moved into the archive, a copy with embedded
+	// metadata or the processed file itself
+	archived := filename
 
-		newFilename, err := file.GenerateFilename(rnd)
+	if s.EmbedMetadata && !file.BornDigital {
+		archived, err = embedCopy(filename, file)
 		if err != nil {
-			return fmt.Errorf("generate filename for %v failed: %w", filename, err)
+			return err
 		}
 
-		// if correspondent could be found, create dir and move the file there
-		// otherwise, move it to the "unknown" directory
-		if file.Correspondent == "" {
-			file.Correspondent = DirectoryUnknownCorrespondent
-		}
+		// the copy is only left over if moving it into the archive failed,
+		// the next attempt creates it again from the unmodified file
+		defer func() {
+			err := os.Remove(archived)
+			if err != nil && !os.IsNotExist(err) {
+				log.Warnf("remove %v: %v", archived, err)
+			}
+		}()
+	}
 
-		newLocation := filepath.Join(s.ArchiveDir, file.Correspondent, newFilename)
+	// the ID is computed from the contents, so it must be done after embedding the metadata
+	id, err := database.FileID(archived)
+	if err != nil {
+		return fmt.Errorf("ID for %v failed: %w", filename, err)
+	}
 
-		err = os.MkdirAll(filepath.Dir(newLocation), newDirMode)
-		if err != nil {
-			return fmt.Errorf("unable to create dir for target file %v: %w", newLocation, err)
+	log = log.WithField("id", id)
+
+	var (
+		existing  database.File
+		duplicate bool
+	)
+
+	// the layout must not change until the file is recorded in the database
+	err = s.Database.WithLayout(func(layout *database.Layout) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// the existing file keeps its metadata and name
+		existing, duplicate = s.Database.GetFile(id)
+		if duplicate {
+			return nil
 		}
 
-		// err = unix.Renameat2(unix.AT_FDCWD, filename, unix.AT_FDCWD, newLocation, unix.RENAME_NOREPLACE)
-		err = unix.Renameat(unix.AT_FDCWD, filename, unix.AT_FDCWD, newLocation)
-		if os.IsExist(err) {
-			log.Warnf("destination file already exists, retrying with new filename")
+		// try to find a unique name, just in case the file at the location already exists
+		for counter := 0; ; counter++ {
+			rnd := ""
+			if counter != 0 {
+				rnd = fmt.Sprintf("- %d", counter)
+			}
 
-			continue
+			newFilename, err := layout.Filename(file, rnd)
+			if err != nil {
+				return fmt.Errorf("generate filename for %v failed: %w", filename, err)
+			}
+
+			// if correspondent could be found, create dir and move the file there
+			// otherwise, move it to the "unknown" directory
+			if file.Correspondent == "" {
+				file.Correspondent = DirectoryUnknownCorrespondent
+			}
+
+			newLocation := filepath.Join(s.ArchiveDir, file.Correspondent, newFilename)
+
+			err = os.MkdirAll(filepath.Dir(newLocation), newDirMode)
+			if err != nil {
+				return fmt.Errorf("unable to create dir for target file %v: %w", newLocation, err)
+			}
+
+			// rename() replaces existing files, so check before (we're holding s.mu)
+			_, err = os.Lstat(newLocation)
+			if err == nil {
+				err = os.ErrExist
+			}
+
+			if errors.Is(err, os.ErrNotExist) {
+				// err = unix.Renameat2(unix.AT_FDCWD, filename, unix.AT_FDCWD, newLocation, unix.RENAME_NOREPLACE)
+				err = unix.Renameat(unix.AT_FDCWD, archived, unix.AT_FDCWD, newLocation)
+			}
+
+			if os.IsExist(err) {
+				log.Warnf("destination file already exists, retrying with new filename")
+
+				continue
+			}
+
+			if err != nil {
+				return fmt.Errorf("move %v -> %v failed: %w", filename, newLocation, err)
+			}
+
+			// the processed file is kept until the copy is in the archive
+			if archived != filename {
+				err = os.Remove(filename)
+				if err != nil {
+					log.Warnf("remove processed file: %v", err)
+				}
+			}
+
+			file.Filename = newFilename
+
+			if job.Original != "" {
+				file.Original = s.moveOriginal(log, job.Original, newLocation)
+			}
+
+			s.Database.SetFile(database.Origin{Source: database.SourceExtracter, User: file.UploadedBy}, id, file)
+
+			if job.Thumbnail != "" {
+				s.moveThumbnail(log, job.Thumbnail, id)
+			}
+
+			err = os.Chmod(newLocation, destinationFileMode)
+			if err != nil {
+				return fmt.Errorf("chmod %v failed: %w", newLocation, err)
+			}
+
+			s.log.WithField("filename", newLocation).WithField("id", id).Infof("new file")
+
+			break
 		}
 
-		if err != nil {
-			return fmt.Errorf("move %v -> %v failed: %w", filename, newLocation, err)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the callbacks may take a while (e.g. sending notifications), so they are
+	// called after the locks have been released
+	if duplicate {
+		// the ID is the hash of the content, so the new file is an exact copy
+		log.Warnf("file is already in the archive as %v/%v, removing the new copy",
+			existing.Correspondent, existing.Filename)
+
+		s.removeDuplicate(log, job)
+
+		if s.OnDuplicate != nil {
+			s.OnDuplicate(id, existing, file)
 		}
 
-		file.Filename = newFilename
-		s.Database.SetFile(id, file)
+		return nil
+	}
 
-		err = os.Chmod(newLocation, destinationFileMode)
+	if s.OnNewFile != nil {
+		s.OnNewFile(id, file)
+	}
+
+	if len(review) > 0 && s.OnReview != nil {
+		s.OnReview(id, file, strings.Join(review, ", "))
+	}
+
+	return nil
+}
+
+// removeDuplicate removes the files of a job for a file which is already in
+// the archive.
+func (s *Extracter) removeDuplicate(log logrus.FieldLogger, job *queue.Job) {
+	for _, name := range []string{job.Filename, job.Original, job.Thumbnail} {
+		if name == "" {
+			continue
+		}
+
+		err := os.Remove(name)
 		if err != nil {
-			return fmt.Errorf("chmod %v failed: %w", newLocation, err)
+			log.Warnf("remove duplicate: %v", err)
 		}
+	}
+}
 
-		s.log.WithField("filename", newLocation).WithField("id", id).Infof("new file")
+// moveThumbnail moves the thumbnail rendered while processing the file to the
+// location for the file ID.
+func (s *Extracter) moveThumbnail(log logrus.FieldLogger, thumbnail, id string) {
+	dest := s.Database.ThumbnailFilename(id)
 
-		if s.OnNewFile != nil {
-			s.OnNewFile(file)
-		}
+	err := os.MkdirAll(filepath.Dir(dest), newDirMode)
+	if err != nil {
+		log.Warnf("create thumbnail dir: %v", err)
 
-		break
+		return
 	}
 
-	return nil
+	err = os.Rename(thumbnail, dest)
+	if err != nil {
+		log.Warnf("move thumbnail %v failed: %v", thumbnail, err)
+	}
 }
 
-func (s *Extracter) Run(ctx context.Context, inFiles <-chan string) error {
-	// process all pre-existing files
-	entries, err := os.ReadDir(s.ProcessedDir)
+// moveOriginal moves the original file (before it was converted to PDF) next
+// to the PDF file at location. The new filename is returned, it is empty if
+// the original file could not be moved.
+func (s *Extracter) moveOriginal(log logrus.FieldLogger, original, location string) string {
+	dest := strings.TrimSuffix(location, filepath.Ext(location)) + filepath.Ext(original)
+
+	_, err := os.Lstat(dest)
+	if err == nil {
+		log.Warnf("original file %v already exists, keeping %v", dest, original)
+
+		return ""
+	}
+
+	err = os.Rename(original, dest)
 	if err != nil {
-		return fmt.Errorf("readdir %v: %w", s.ProcessedDir, err)
+		log.Warnf("move original file %v failed: %v", original, err)
+
+		return ""
 	}
 
-	for _, entry := range entries {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-		}
+	err = os.Chmod(dest, destinationFileMode)
+	if err != nil {
+		log.Warnf("chmod %v failed: %v", dest, err)
+	}
 
-		filename := filepath.Join(s.ProcessedDir, entry.Name())
+	return filepath.Base(dest)
+}
 
-		err := s.processFile(filename)
-		if err != nil {
-			s.log.WithField("filename", filename).Warnf("error: %v", err)
-		}
+// embedCopy copies filename to a hidden file in the same directory and embeds
+// the metadata of f into it. The name of the copy is returned. The processed
+// file is not modified, so a failed attempt can be retried and yields the
+// same ID.
+func embedCopy(filename string, f database.File) (string, error) {
+	// exiftool needs the extension to detect the file type
+	dest := filepath.Join(filepath.Dir(filename), ".embed-"+filepath.Base(filename))
+
+	err := os.Remove(dest)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("remove old copy: %w", err)
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case filename := <-inFiles:
-			err := s.processFile(filename)
-			if err != nil {
-				s.log.WithField("filename", filename).Warnf("error: %v", err)
-			}
-		}
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("embed metadata: %w", err)
+	}
+
+	err = os.WriteFile(dest, buf, 0600)
+	if err != nil {
+		return "", fmt.Errorf("embed metadata: %w", err)
 	}
+
+	err = database.EmbedMetadata(dest, f)
+	if err != nil {
+		_ = os.Remove(dest)
+
+		return "", fmt.Errorf("embed metadata: %w", err)
+	}
+
+	return dest, nil
 }
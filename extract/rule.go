@@ -0,0 +1,71 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// Config configures the extraction of data from files.
+type Config struct {
+	// Rules assign a document type and tags to files.
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// Rule assigns a document type and tags to files which contain a text and/or
+// belong to a correspondent. If both are set, both must match.
+type Rule struct {
+	Contains      string `json:"contains,omitempty"`
+	Correspondent string `json:"correspondent,omitempty"`
+
+	Type string   `json:"type,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Validate checks the configuration for errors.
+func (c Config) Validate() error {
+	for i, rule := range c.Rules {
+		if rule.Contains == "" && rule.Correspondent == "" {
+			return fmt.Errorf("rule %d: neither contains nor correspondent set", i+1)
+		}
+
+		if rule.Type == "" && len(rule.Tags) == 0 {
+			return fmt.Errorf("rule %d: neither type nor tags set", i+1)
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true if the rule applies to a file from correspondent with text.
+func (r Rule) Matches(correspondent string, text []byte) bool {
+	if r.Correspondent != "" && !strings.EqualFold(r.Correspondent, correspondent) {
+		return false
+	}
+
+	if r.Contains != "" && !bytes.Contains(bytes.ToLower(text), bytes.ToLower([]byte(r.Contains))) {
+		return false
+	}
+
+	return true
+}
+
+// ApplyRules adds the tags of all matching rules to file. The type is set
+// from the first matching rule, unless file has a type already.
+func ApplyRules(rules []Rule, file *database.File, text []byte) {
+	for _, rule := range rules {
+		if !rule.Matches(file.Correspondent, text) {
+			continue
+		}
+
+		if file.Type == "" {
+			file.Type = rule.Type
+		}
+
+		file.Tags = append(file.Tags, rule.Tags...)
+	}
+
+	file.Tags = database.NormalizeTags(file.Tags)
+}
@@ -0,0 +1,31 @@
+package extract
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+func TestApplyRules(t *testing.T) {
+	t.Parallel()
+
+	rules := []Rule{
+		{Contains: "Steuerbescheid", Type: "notice", Tags: []string{"tax"}},
+		{Correspondent: "finanzamt", Tags: []string{"Tax", "authority"}},
+		{Contains: "Rechnung", Type: "invoice"},
+		{Correspondent: "bank", Contains: "Steuer", Tags: []string{"bank"}},
+	}
+
+	file := database.File{Correspondent: "Finanzamt", Tags: []string{"2023"}}
+	ApplyRules(rules, &file, []byte("Ihr STEUERBESCHEID und keine Rechnung"))
+
+	if file.Type != "notice" {
+		t.Errorf("want type notice, got %q", file.Type)
+	}
+
+	want := []string{"2023", "authority", "tax"}
+	if !reflect.DeepEqual(file.Tags, want) {
+		t.Errorf("want tags %v, got %v", want, file.Tags)
+	}
+}
@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrIncompletePDF is returned by CheckPDF for files which are not a complete
+// PDF document, e.g. because the upload was interrupted.
+var ErrIncompletePDF = errors.New("incomplete PDF file")
+
+// pdfTrailerSize is the number of bytes at the end of a file in which the
+// end-of-file marker must be found.
+const pdfTrailerSize = 1024
+
+// CheckPDF returns an error wrapping ErrIncompletePDF if filename does not
+// start with the PDF header or has no end-of-file marker ("%%EOF") within
+// the last 1024 bytes.
+func CheckPDF(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("check pdf: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	header := make([]byte, 5)
+
+	_, err = io.ReadFull(f, header)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%v: file too short: %w", filename, ErrIncompletePDF)
+	}
+
+	if err != nil {
+		return fmt.Errorf("check pdf: %w", err)
+	}
+
+	if !bytes.Equal(header, []byte("%PDF-")) {
+		return fmt.Errorf("%v: header not found: %w", filename, ErrIncompletePDF)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("check pdf: %w", err)
+	}
+
+	offset := max(fi.Size()-pdfTrailerSize, 0)
+
+	trailer := make([]byte, fi.Size()-offset)
+
+	_, err = f.ReadAt(trailer, offset)
+	if err != nil {
+		return fmt.Errorf("check pdf: %w", err)
+	}
+
+	if !bytes.Contains(trailer, []byte("%%EOF")) {
+		return fmt.Errorf("%v: end-of-file marker not found: %w", filename, ErrIncompletePDF)
+	}
+
+	return nil
+}
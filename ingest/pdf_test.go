@@ -0,0 +1,49 @@
+package ingest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckPDF(t *testing.T) {
+	t.Parallel()
+
+	body := "%PDF-1.7\n" + strings.Repeat("1 0 obj\n<< >>\nendobj\n", 200)
+
+	tests := []struct {
+		name     string
+		content  string
+		complete bool
+	}{
+		{"complete.pdf", body + "trailer\n<< /Root 1 0 R >>\n%%EOF\n", true},
+		{"garbage.pdf", body + "%%EOF\n" + strings.Repeat("\x00", 500), true},
+		{"truncated.pdf", body, false},
+		{"eof-too-early.pdf", "%PDF-1.7\n%%EOF\n" + body, false},
+		{"no-header.pdf", "<html>%%EOF", false},
+		{"short.pdf", "%PD", false},
+		{"empty.pdf", "", false},
+	}
+
+	dir := t.TempDir()
+
+	for _, test := range tests {
+		filename := filepath.Join(dir, test.name)
+
+		err := os.WriteFile(filename, []byte(test.content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = CheckPDF(filename)
+		if test.complete && err != nil {
+			t.Errorf("%v: unexpected error %v", test.name, err)
+		}
+
+		if !test.complete && !errors.Is(err, ErrIncompletePDF) {
+			t.Errorf("%v: want ErrIncompletePDF, got %v", test.name, err)
+		}
+	}
+}
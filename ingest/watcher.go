@@ -5,12 +5,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/rjeczalik/notify"
 	"github.com/sirupsen/logrus"
 )
 
-// Watcher calls OnNewFile when a new file is placed in Dir.
+// Ingest sources, they are recorded for each new file.
+const (
+	// SourceIncoming is used for files placed in incoming/ directly.
+	SourceIncoming = "incoming"
+
+	// SourceWebDAV is used for files uploaded via WebDAV.
+	SourceWebDAV = "webdav"
+)
+
+// Watcher calls OnNewFile when a new file is placed in Dir. Hidden files
+// (e.g. temporary files written while uploading) are ignored.
 type Watcher struct {
 	Dir string
 
@@ -45,6 +56,10 @@ func (w *Watcher) Run(ctx context.Context) error {
 		default:
 		}
 
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
 		filename := filepath.Join(w.Dir, entry.Name())
 
 		w.log.WithField("filename", filename).Infof("found new file")
@@ -71,6 +86,10 @@ outer:
 				return nil
 			}
 
+			if strings.HasPrefix(filepath.Base(ev.Path()), ".") {
+				continue
+			}
+
 			w.OnNewFile(ev.Path())
 		}
 	}
@@ -1,3 +1,5 @@
+//go:build cgo && !kqueue
+
 package ingest
 
 import "github.com/rjeczalik/notify"
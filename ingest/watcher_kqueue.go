@@ -0,0 +1,10 @@
+//go:build darwin && (kqueue || !cgo)
+
+package ingest
+
+import "github.com/rjeczalik/notify"
+
+// watchDir uses kqueue, the FSEvents backend in watcher_darwin.go requires cgo.
+func watchDir(dirname string, ch chan<- notify.EventInfo) error {
+	return notify.Watch(dirname, ch, notify.Create, notify.Rename)
+}
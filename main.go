@@ -4,20 +4,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
-	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/fd0/nepomuk/api"
+	"github.com/fd0/nepomuk/config"
 	"github.com/fd0/nepomuk/database"
 	"github.com/fd0/nepomuk/extract"
 	"github.com/fd0/nepomuk/ingest"
 	"github.com/fd0/nepomuk/notify"
 	"github.com/fd0/nepomuk/process"
+	"github.com/fd0/nepomuk/queue"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"golang.org/x/net/webdav"
@@ -51,13 +52,18 @@ func CheckTargetDir(dir string) error {
 
 var log *logrus.Logger
 
-const defaultChannelBufferSize = 500
-
 type Options struct {
-	BaseDir      string
-	ListenWebDAV string
-	LogLevel     string
-	Verbose      bool
+	BaseDir        string
+	ConfigFile     string
+	ListenWebDAV   string
+	ListenAPI      string
+	ListenCalendar string
+	LogLevel       string
+	Verbose        bool
+	ProcessWorkers int
+	ProcessTimeout time.Duration
+	ExtractWorkers int
+	DryRun         bool
 }
 
 func main() {
@@ -65,9 +71,16 @@ func main() {
 
 	fs := pflag.NewFlagSet("nepomuk", pflag.ContinueOnError)
 	fs.StringVar(&opts.BaseDir, "base-dir", "archive", "archive base `directory`")
+	fs.StringVar(&opts.ConfigFile, "config", "", "read configuration from `file` (default: base-dir/.nepomuk/config.json)")
 	fs.StringVar(&opts.ListenWebDAV, "listen-webdav", ":8080", "run WebDAV-Server on `addr:port`")
+	fs.StringVar(&opts.ListenAPI, "listen-api", "localhost:8081", "run API server on `addr:port`")
+	fs.StringVar(&opts.ListenCalendar, "listen-calendar", "", "serve the calendar feed of due dates on `addr:port`")
 	fs.StringVar(&opts.LogLevel, "log-level", "debug", "set log level")
 	fs.BoolVar(&opts.Verbose, "verbose", false, "print verbose messages")
+	fs.IntVar(&opts.ProcessWorkers, "process-workers", 2, "process `n` files concurrently (OCR)")
+	fs.DurationVar(&opts.ProcessTimeout, "process-timeout", 30*time.Minute, "abort processing a single file after `duration`")
+	fs.IntVar(&opts.ExtractWorkers, "extract-workers", 4, "extract data from `n` files concurrently")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "only print what would be done (migrate)")
 
 	err := fs.Parse(os.Args)
 	if errors.Is(err, pflag.ErrHelp) {
@@ -79,154 +92,191 @@ func main() {
 		os.Exit(1)
 	}
 
-	err = run(opts)
+	// the first argument is the name of the program
+	if args := fs.Args(); len(args) > 1 {
+		err = runClient(opts, args[1:])
+	} else {
+		err = run(opts)
+	}
+
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-// we need to use the dot to specify millisecond precision, it will be replaced later
-const uploadFilenameTimeFormat = "20060102-150405.000000"
-
-func runWebDAVServer(ctx context.Context, wg *errgroup.Group, logger *logrus.Logger, addr, incomingDir string) {
-	log := logger.WithField("component", "webdav-server")
-
-	log.Debugf("start on %v", addr)
+// enqueueExisting adds all files in dir to the queue for stage. Files which
+// are already known to the queue are ignored.
+func enqueueExisting(q *queue.Queue, dir string, stage queue.Stage) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("readdir %v: %w", dir, err)
+	}
 
-	var logRequest func(*http.Request, error)
-	if logger.Level >= logrus.DebugLevel {
-		logRequest = func(req *http.Request, err error) {
-			log.Printf("%v %v -> %v", req.Method, req.URL.Path, err)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
 		}
+
+		q.Add(queue.Job{
+			Filename: filepath.Join(dir, entry.Name()),
+			Stage:    stage,
+		})
 	}
 
-	// we use a MemFS to temporarily store files
-	filesystem := webdav.NewMemFS()
+	return nil
+}
+
+// newWebDAVHandler returns the handler for the WebDAV server, uploads are
+// passed to stager when they are complete. If users are configured, they
+// must authenticate and each user only sees their own directory.
+func newWebDAVHandler(ctx context.Context, log logrus.FieldLogger, logRequest func(*http.Request, error), users []config.WebDAVUser, stager *uploadStager) (http.Handler, error) {
+	// files are passed on when the client closes them after the upload
+	filesystem := uploadFS{
+		FileSystem: webdav.Dir(stager.Dir),
+		OnComplete: stager.Accept,
+	}
 	locksystem := webdav.NewMemLS()
 
-	handler := &webdav.Handler{
+	var handler http.Handler = &webdav.Handler{
 		FileSystem: filesystem,
 		LockSystem: locksystem,
 		Logger:     logRequest,
 	}
 
-	server := http.Server{
-		Addr:    addr,
-		Handler: handler,
-	}
-
-	// watch the file system for new files and move them into incomingDir
-	wg.Go(func() error {
-		ticker := time.NewTicker(20 * time.Millisecond)
+	if len(users) > 0 {
+		authUsers := make([]webDAVUser, 0, len(users))
 
-		walkFS := newWebDAVFS(filesystem)
+		for _, user := range users {
+			root := "/" + user.RootDir()
 
-	outer:
-		for {
-			select {
-			case <-ticker.C:
-			case <-ctx.Done():
-				break outer
+			err := filesystem.Mkdir(ctx, root, 0700)
+			if err != nil && !errors.Is(err, os.ErrExist) {
+				return nil, fmt.Errorf("create webdav root for %v: %w", user.Name, err)
 			}
 
-			err := fs.WalkDir(walkFS, "", func(filename string, d fs.DirEntry, err error) error {
-				if err != nil {
-					return err
-				}
-
-				// ignore dirs
-				if d.IsDir() {
-					return nil
-				}
-
-				// ignore files with . or _ as first characters
-				if filename != "" && (filename[0] == '.' || filename[0] == '_') {
-					log.Tracef("ignore file with special filename %v", filename)
+			authUsers = append(authUsers, webDAVUser{
+				WebDAVUser: user,
+				handler: &webdav.Handler{
+					FileSystem: rootFS{fs: filesystem, root: root},
+					LockSystem: rootLS{ls: locksystem, root: root},
+					Logger:     logRequest,
+				},
+			})
 
-					return nil
-				}
+			stager.Uploaders[user.RootDir()] = user
+		}
 
-				fi, err := d.Info()
-				if err != nil {
-					log.Warnf("get FileInfo for %v: %v", filename, err)
+		handler = newWebDAVAuth(log, authUsers)
+	} else {
+		log.Warnf("no users configured, anybody can upload files")
+	}
 
-					return nil
-				}
+	return withUploadSize(handler), nil
+}
 
-				// ignore very small files
-				if fi.Size() < 1000 {
-					log.Tracef("ignore small file %v (%d bytes)", filename, fi.Size())
+func runWebDAVServer(ctx context.Context, wg *errgroup.Group, logger *logrus.Logger, addr string, cfg config.WebDAVConfig, stagingDir, incomingDir string, q *queue.Queue) error {
+	log := logger.WithField("component", "webdav-server")
 
-					return nil
-				}
+	log.Debugf("start on %v", addr)
 
-				// ignore very new files
-				if time.Since(fi.ModTime()) < 200*time.Millisecond {
-					log.Tracef("ignore %v, too new", filename)
+	var logRequest func(*http.Request, error)
+	if logger.Level >= logrus.DebugLevel {
+		logRequest = func(req *http.Request, err error) {
+			log.Printf("%v %v -> %v", req.Method, req.URL.Path, err)
+		}
+	}
 
-					return nil
-				}
+	// uploads are stored on disk, so large files do not need to fit into
+	// memory and complete uploads survive a restart
+	err := os.MkdirAll(stagingDir, 0700)
+	if err != nil {
+		return fmt.Errorf("create staging dir: %w", err)
+	}
 
-				// try to get lock, ignore locked files
-				token, err := locksystem.Create(time.Now(), webdav.LockDetails{
-					Root:      filename,
-					Duration:  -1,
-					OwnerXML:  "nepomuk",
-					ZeroDepth: true,
-				})
+	// users upload into their own directory, the uploader is found by the
+	// first element of the path
+	stager := &uploadStager{
+		Dir:         stagingDir,
+		IncomingDir: incomingDir,
+		FailedDir:   q.FailedDir,
+		Queue:       q,
+		MinSize:     cfg.MinSize,
+		Uploaders:   make(map[string]config.WebDAVUser, len(cfg.Users)),
+		log:         log,
+	}
 
-				if err != nil {
-					log.Debugf("did not get lock for %v, skipping", filename)
+	handler, err := newWebDAVHandler(ctx, log, logRequest, cfg.Users, stager)
+	if err != nil {
+		return err
+	}
 
-					return nil
-				}
+	server := http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
 
-				log.Tracef("got lock, token %v", token)
-				defer func() {
-					err := locksystem.Unlock(time.Now(), token)
-					if err != nil {
-						log.Debugf("unlock return error: %v", err)
-					}
-				}()
+	// pass on uploads which were completed before a restart
+	err = stager.AcceptExisting()
+	if err != nil {
+		return fmt.Errorf("staged uploads: %w", err)
+	}
 
-				buf, err := fs.ReadFile(walkFS, filename)
-				if err != nil {
-					return fmt.Errorf("readfile: %w", err)
-				}
+	// ensure cancelling the context stops the server
+	wg.Go(func() error {
+		<-ctx.Done()
+		log.Debugf("shutdown webdav server")
 
-				log.Debugf("found new file %v, %d bytes\n", filename, len(buf))
+		// pass a cancelled context to Shutdown so it terminates directly
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
 
-				err = walkFS.RemoveAll(filename)
-				if err != nil {
-					return fmt.Errorf("remove %v: %w", filename, err)
-				}
+		err := server.Shutdown(ctx)
+		if err != nil {
+			return fmt.Errorf("shutdown webdav server: %w", err)
+		}
 
-				name := time.Now().Format(uploadFilenameTimeFormat)
-				// replace the dot used for specifying millisecond precision
-				name = strings.ReplaceAll(name, ".", "_")
-				name += path.Ext(filename)
+		return nil
+	})
 
-				err = os.WriteFile(filepath.Join(incomingDir, name), buf, 0600)
-				if err != nil {
-					return fmt.Errorf("write to incoming dir: %w", err)
-				}
+	wg.Go(func() error {
+		var err error
+		if cfg.TLSCert != "" {
+			err = server.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
 
-				return nil
-			})
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
 
-			if err != nil {
-				return fmt.Errorf("walk() FS: %w", err)
-			}
+		if err != nil {
+			return fmt.Errorf("listen webdav: %w", err)
 		}
 
 		return nil
 	})
 
+	return nil
+}
+
+// runHTTPServer serves handler on addr until ctx is cancelled, name is used
+// for log and error messages.
+func runHTTPServer(ctx context.Context, wg *errgroup.Group, logger *logrus.Logger, name, addr string, handler http.Handler) {
+	log := logger.WithField("component", name+"-server")
+
+	log.Debugf("start on %v", addr)
+
+	server := http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+
 	// ensure cancelling the context stops the server
 	wg.Go(func() error {
 		<-ctx.Done()
-		log.Debugf("shutdown webdav server")
+		log.Debugf("shutdown %v server", name)
 
 		// pass a cancelled context to Shutdown so it terminates directly
 		ctx, cancel := context.WithCancel(ctx)
@@ -234,7 +284,7 @@ func runWebDAVServer(ctx context.Context, wg *errgroup.Group, logger *logrus.Log
 
 		err := server.Shutdown(ctx)
 		if err != nil {
-			return fmt.Errorf("shutdown webdav server: %w", err)
+			return fmt.Errorf("shutdown %v server: %w", name, err)
 		}
 
 		return nil
@@ -247,13 +297,22 @@ func runWebDAVServer(ctx context.Context, wg *errgroup.Group, logger *logrus.Log
 		}
 
 		if err != nil {
-			return fmt.Errorf("listen webdav: %w", err)
+			return fmt.Errorf("listen %v: %w", name, err)
 		}
 
 		return nil
 	})
 }
 
+// duplexGroup keeps both halves of a duplex scan together and in order.
+func duplexGroup(filename string) string {
+	if strings.HasPrefix(filepath.Base(filename), "duplex-") {
+		return "duplex"
+	}
+
+	return ""
+}
+
 func run(opts Options) error {
 	// configure logging
 	log = logrus.New()
@@ -274,10 +333,44 @@ func run(opts Options) error {
 		return err
 	}
 
+	if opts.ConfigFile == "" {
+		opts.ConfigFile = filepath.Join(opts.BaseDir, ".nepomuk/config.json")
+	}
+
+	cfg, err := config.Load(opts.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	// notifications used to be configured via environment variables
+	if len(cfg.Notify.Notifiers) == 0 {
+		if n, ok := notify.PushoverFromEnv(); ok {
+			cfg.Notify.Notifiers = append(cfg.Notify.Notifiers, n)
+		}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return fmt.Errorf("config %v: %w", opts.ConfigFile, err)
+	}
+
+	if opts.ListenCalendar != "" && cfg.Calendar.Token == "" {
+		return fmt.Errorf("config %v: calendar.token must be set for --listen-calendar", opts.ConfigFile)
+	}
+
+	notifier, err := notify.NewDispatcher(cfg.Notify)
+	if err != nil {
+		return err
+	}
+
+	notifier.SetLogger(log)
+
 	incomingDir := filepath.Join(opts.BaseDir, "incoming")
 	processedDir := filepath.Join(opts.BaseDir, ".nepomuk/processed")
+	originalsDir := filepath.Join(opts.BaseDir, ".nepomuk/originals")
+	failedDir := filepath.Join(opts.BaseDir, "failed")
 
-	for _, dir := range []string{incomingDir, processedDir, opts.BaseDir} {
+	for _, dir := range []string{incomingDir, processedDir, originalsDir, failedDir, opts.BaseDir} {
 		err = CheckTargetDir(dir)
 		if err != nil {
 			return err
@@ -293,14 +386,79 @@ func run(opts Options) error {
 	}
 
 	db.SetLogger(log)
+	db.ImportEmbedded = cfg.Database.EmbedMetadata
+	db.Sidecars = cfg.Database.Sidecars
+	db.SoftDelete = cfg.Database.Trash.Enabled
+	db.Retention = time.Duration(cfg.Database.Trash.RetentionDays) * 24 * time.Hour
+	db.History = database.NewHistory(filepath.Join(opts.BaseDir, ".nepomuk/history.jsonl"))
 
-	db.OnChange = func(id string, _, _ database.File) {
-		log.Infof("database: data for file %v changed, saving database", id)
+	layout, err := database.ParseLayout(cfg.Database.Layout)
+	if err != nil {
+		return err
+	}
+
+	if db.Layout().String() != layout.String() {
+		if len(db.Files()) == 0 {
+			db.SetLayout(layout)
+		} else {
+			log.Warnf("archive uses layout %q, run 'nepomuk migrate' to change it to %q", db.Layout(), layout)
+		}
+	}
 
+	if cfg.Notify.Digest.Schedule != "" {
+		notifier.Digest, err = notify.NewDigest(cfg.Notify.Digest, filepath.Join(opts.BaseDir, ".nepomuk/digest.json"))
+		if err != nil {
+			return err
+		}
+
+		notifier.Digest.Unknown = func() []database.File {
+			var files []database.File
+
+			for _, file := range db.Files() {
+				if file.Correspondent == extract.DirectoryUnknownCorrespondent {
+					files = append(files, file)
+				}
+			}
+
+			return files
+		}
+	}
+
+	// documentEvent returns an event for a file in the archive
+	documentEvent := func(kind, id string, file database.File) notify.Event {
+		ev := notify.Event{
+			Kind:     kind,
+			ID:       id,
+			File:     file,
+			Document: filepath.Join(opts.BaseDir, file.Correspondent, filepath.FromSlash(file.Filename)),
+		}
+
+		_, err := os.Stat(db.ThumbnailFilename(id))
+		if err == nil {
+			ev.Thumbnail = db.ThumbnailFilename(id)
+		}
+
+		return ev
+	}
+
+	saveDatabase := func() {
 		err := db.Save(filepath.Join(opts.BaseDir, ".nepomuk/db.json"))
 		if err != nil {
 			log.Printf("database: save error: %v", err)
 		}
+
+		if cfg.Database.TagLinks {
+			err = db.SyncTagLinks()
+			if err != nil {
+				log.Warnf("database: %v", err)
+			}
+		}
+	}
+
+	db.OnChange = func(id string, _, _ database.File) {
+		log.Infof("database: data for file %v changed, saving database", id)
+
+		saveDatabase()
 	}
 
 	err = db.Scan()
@@ -308,6 +466,13 @@ func run(opts Options) error {
 		log.Warnf("db scan returned error: %v", err)
 	}
 
+	if cfg.Database.TagLinks {
+		err = db.SyncTagLinks()
+		if err != nil {
+			log.Warnf("database: %v", err)
+		}
+	}
+
 	// create new root context, cancel on SIGINT
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
@@ -315,16 +480,131 @@ func run(opts Options) error {
 	// couple this context with an errgroup
 	wg, ctx := errgroup.WithContext(ctx)
 
-	newFiles := make(chan string, defaultChannelBufferSize)
+	q := queue.New(filepath.Join(opts.BaseDir, ".nepomuk/queue.json"), failedDir)
+	q.GroupFunc = duplexGroup
+	q.OnFailed = func(job queue.Job, err error) {
+		_ = notifier.Event(ctx, notify.Event{
+			Kind:  notify.EventFailed,
+			File:  job.File,
+			Path:  q.FailedFilename(job),
+			Error: err.Error(),
+		})
+	}
+	q.SetLogger(log)
+
+	err = q.Load()
+	if err != nil {
+		return err
+	}
 
-	runWebDAVServer(ctx, wg, log, opts.ListenWebDAV, incomingDir)
+	// files which have been processed before but are not in the queue
+	err = enqueueExisting(q, processedDir, queue.StageExtract)
+	if err != nil {
+		return err
+	}
+
+	err = runWebDAVServer(ctx, wg, log, opts.ListenWebDAV, cfg.WebDAV, filepath.Join(opts.BaseDir, uploadStagingDir), incomingDir, q)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Notify.Reminders.Enabled {
+		wg.Go(func() error {
+			return notifier.RunReminders(ctx, notify.Reminders{
+				Config: cfg.Notify.Reminders,
+				Files:  db.Files,
+				Prepare: func(ev *notify.Event) {
+					doc := documentEvent(ev.Kind, ev.ID, ev.File)
+					ev.Document, ev.Thumbnail = doc.Document, doc.Thumbnail
+				},
+			})
+		})
+	}
+
+	if cfg.Notify.Missing.Enabled {
+		wg.Go(func() error {
+			return notifier.RunMissing(ctx, notify.Missing{
+				Config: cfg.Notify.Missing,
+				Recurring: func() []database.Recurring {
+					return db.Recurring(extract.DirectoryUnknownCorrespondent)
+				},
+			})
+		})
+	}
+
+	if notifier.Digest != nil {
+		wg.Go(func() error {
+			return notifier.RunDigest(ctx)
+		})
+	}
+
+	if cfg.Notify.DiskMinFreePercent > 0 {
+		wg.Go(func() error {
+			watchDiskSpace(ctx, log, opts.BaseDir, cfg.Notify.DiskMinFreePercent, notifier)
+
+			return nil
+		})
+	}
+
+	// purge files from the trash after the retention period
+	if db.SoftDelete && db.Retention > 0 {
+		wg.Go(func() error {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+
+			for {
+				n, err := db.PurgeExpired(time.Now())
+				if err != nil {
+					log.Warnf("database: %v", err)
+				}
+
+				if n > 0 {
+					saveDatabase()
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		})
+	}
+
+	if opts.ListenAPI != "" {
+		srv := &api.Server{
+			Database:   db,
+			Queue:      q,
+			Layout:     layout,
+			OnMigrate:  saveDatabase,
+			OnPurge:    saveDatabase,
+			OnSettings: saveDatabase,
+			Unknown:    extract.DirectoryUnknownCorrespondent,
+		}
+		srv.SetLogger(log)
+
+		runHTTPServer(ctx, wg, log, "api", opts.ListenAPI, srv.Handler())
+	}
+
+	// the calendar feed has its own listener, so it can be reached from other
+	// machines without exposing the API
+	if opts.ListenCalendar != "" {
+		srv := &api.Server{Database: db}
+		srv.SetLogger(log)
+
+		runHTTPServer(ctx, wg, log, "calendar", opts.ListenCalendar, srv.CalendarHandler(cfg.Calendar.Token))
+	}
 
 	// watch for new files in incoming/
 	wg.Go(func() error {
 		watcher := &ingest.Watcher{
 			Dir: incomingDir,
 			OnNewFile: func(filename string) {
-				newFiles <- filename
+				q.Add(queue.Job{
+					Filename: filename,
+					Stage:    queue.StageProcess,
+					Source:   ingest.SourceIncoming,
+				})
 			},
 		}
 		watcher.SetLogger(log)
@@ -332,37 +612,76 @@ func run(opts Options) error {
 		return watcher.Run(ctx)
 	})
 
-	processedFiles := make(chan string, defaultChannelBufferSize)
-
 	// process files received via incoming/
 	wg.Go(func() error {
 		processor := &process.Processor{
 			ProcessedDir: processedDir,
-			OnFileProcessed: func(filename string) {
-				processedFiles <- filename
-			},
+			OriginalsDir: originalsDir,
+			ThumbnailDir: filepath.Join(opts.BaseDir, database.ThumbnailDir),
+			Config:       cfg.Processing,
+			Queue:        q,
 		}
 
 		processor.SetLogger(log)
 
-		return processor.Run(ctx, newFiles)
+		workers := queue.Workers{
+			Count:   opts.ProcessWorkers,
+			Timeout: opts.ProcessTimeout,
+		}
+
+		return q.Run(ctx, queue.StageProcess, workers, func(ctx context.Context, job *queue.Job) (string, error) {
+			return processor.ProcessFile(ctx, job)
+		})
 	})
 
 	// extract data and sort processed files
 	wg.Go(func() error {
+		uploaderCorrespondents := make(map[string]string)
+		for _, user := range cfg.WebDAV.Users {
+			if user.Correspondent != "" {
+				uploaderCorrespondents[user.Name] = user.Correspondent
+			}
+		}
+
 		extracter := extract.Extracter{
-			Database:       db,
-			ArchiveDir:     opts.BaseDir,
-			ProcessedDir:   processedDir,
-			Correspondents: []extract.Correspondent{},
-			OnNewFile: func(file database.File) {
-				notify.Notify(log, file)
+			Database:               db,
+			ArchiveDir:             opts.BaseDir,
+			ProcessedDir:           processedDir,
+			Correspondents:         []extract.Correspondent{},
+			Rules:                  cfg.Extract.Rules,
+			EmbedMetadata:          cfg.Database.EmbedMetadata,
+			UploaderCorrespondents: uploaderCorrespondents,
+			OnNewFile: func(id string, file database.File) {
+				kind := notify.EventFiled
+				if file.Correspondent == extract.DirectoryUnknownCorrespondent {
+					kind = notify.EventUnknown
+				}
+
+				_ = notifier.Event(ctx, documentEvent(kind, id, file))
+			},
+			OnDuplicate: func(id string, existing, file database.File) {
+				ev := documentEvent(notify.EventDuplicate, id, file)
+				ev.Existing = existing
+
+				_ = notifier.Event(ctx, ev)
+			},
+			OnReview: func(id string, file database.File, reason string) {
+				ev := documentEvent(notify.EventReview, id, file)
+				ev.Reason = reason
+
+				_ = notifier.Event(ctx, ev)
 			},
 		}
 
 		extracter.SetLogger(log)
 
-		return extracter.Run(ctx, processedFiles)
+		workers := queue.Workers{
+			Count: opts.ExtractWorkers,
+		}
+
+		return q.Run(ctx, queue.StageExtract, workers, func(_ context.Context, job *queue.Job) (string, error) {
+			return "", extracter.ProcessFile(job)
+		})
 	})
 
 	// watch archive directory and make sure files are in sync between the database and the filenames
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// What can be attached to notifications.
+const (
+	AttachNone      = ""
+	AttachThumbnail = "thumbnail"
+	AttachDocument  = "document"
+)
+
+// DefaultMaxAttachmentSize is the size limit for attachments unless
+// configured otherwise.
+const DefaultMaxAttachmentSize = 5 << 20
+
+// Attachment is a file sent along with a message.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// ErrAttachmentTooLarge is returned by loadAttachment for files above the limit.
+var ErrAttachmentTooLarge = fmt.Errorf("attachment too large")
+
+// loadAttachment reads filename, which must not be larger than maxSize bytes.
+func loadAttachment(filename string, maxSize int64) (*Attachment, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open attachment: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// read one more byte to detect files which are too large
+	data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read attachment: %w", err)
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%v: %w (limit %d bytes)", filename, ErrAttachmentTooLarge, maxSize)
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &Attachment{
+		Filename:    filepath.Base(filename),
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// attachment returns the attachment for ev configured for the notifier, or
+// nil if there is none.
+func (c NotifierConfig) attachment(ev Event) (*Attachment, error) {
+	var filename string
+
+	switch c.Attach {
+	case AttachThumbnail:
+		filename = ev.Thumbnail
+	case AttachDocument:
+		filename = ev.Document
+	}
+
+	if filename == "" {
+		return nil, nil
+	}
+
+	maxSize := c.MaxAttachmentSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxAttachmentSize
+	}
+
+	return loadAttachment(filename, maxSize)
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttachment(t *testing.T) {
+	t.Parallel()
+
+	thumbnail := filepath.Join(t.TempDir(), "abc.jpg")
+
+	err := os.WriteFile(thumbnail, []byte("fake jpeg"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, requests := testServer(t, `{"content_uri": "mxc://example.com/abc"}`, nil)
+
+	cfg := Config{
+		Notifiers: []NotifierConfig{
+			{
+				Name:    "hook",
+				Type:    "webhook",
+				Options: json.RawMessage(`{"url": "` + srv.URL + `/hook"}`),
+				Attach:  AttachThumbnail,
+			},
+			{
+				Name:              "small",
+				Type:              "webhook",
+				Options:           json.RawMessage(`{"url": "` + srv.URL + `/small"}`),
+				Attach:            AttachThumbnail,
+				MaxAttachmentSize: 4,
+			},
+		},
+		Routes: []Route{
+			{Notifiers: []string{"hook"}},
+		},
+	}
+
+	d, err := NewDispatcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := Event{Kind: EventFiled, Thumbnail: thumbnail}
+
+	err = d.Event(context.Background(), ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg Message
+
+	req := <-requests
+
+	err = json.Unmarshal([]byte(req.Body), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Attachment{Filename: "abc.jpg", ContentType: "image/jpeg", Data: []byte("fake jpeg")}
+	if msg.Attachment == nil || msg.Attachment.Filename != want.Filename ||
+		msg.Attachment.ContentType != want.ContentType || !bytes.Equal(msg.Attachment.Data, want.Data) {
+		t.Errorf("wrong attachment, want %+v, got %+v", want, msg.Attachment)
+	}
+
+	// files above the limit are not attached
+	d.Routes = []Route{{Notifiers: []string{"small"}}}
+
+	err = d.Event(context.Background(), ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = <-requests
+	if req.Path != "/small" || strings.Contains(req.Body, "attachment") {
+		t.Errorf("unexpected attachment in %+v", req)
+	}
+
+	// matrix uploads the attachment and sends an image message
+	m, err := New(NotifierConfig{
+		Type:    "matrix",
+		Options: json.RawMessage(`{"homeserver": "` + srv.URL + `", "access_token": "secret", "room_id": "!room:example.com"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Notify(context.Background(), Message{Title: "foo", Attachment: &want})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-requests
+
+	upload := <-requests
+	if !strings.HasPrefix(upload.Path, "/_matrix/media/v3/upload") || upload.Body != "fake jpeg" ||
+		upload.Header.Get("Content-Type") != "image/jpeg" {
+		t.Errorf("unexpected upload %+v", upload)
+	}
+
+	image := <-requests
+	if !strings.Contains(image.Body, `"msgtype":"m.image"`) || !strings.Contains(image.Body, `"url":"mxc://example.com/abc"`) {
+		t.Errorf("unexpected image message %+v", image)
+	}
+}
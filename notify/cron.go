@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron-like schedule with the five fields minute, hour, day of
+// month, month and day of week, e.g. "0 8 * * 1" for Mondays at 8:00. Fields
+// can contain lists ("1,15"), ranges ("1-5") and steps ("*/2"). The
+// shortcuts @hourly, @daily and @weekly are supported as well.
+type Schedule struct {
+	spec string
+
+	minute, hour, dom, month, dow uint64
+
+	// as in cron, if both day fields are restricted, either must match
+	domAny, dowAny bool
+}
+
+var scheduleShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseSchedule parses a cron-like schedule.
+func ParseSchedule(spec string) (*Schedule, error) {
+	expanded := spec
+	if s, ok := scheduleShortcuts[strings.TrimSpace(spec)]; ok {
+		expanded = s
+	}
+
+	fields := strings.Fields(expanded)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	s := &Schedule{spec: spec}
+
+	var err error
+
+	for _, f := range []struct {
+		dst      *uint64
+		min, max int
+		names    []string
+		name     string
+	}{
+		{&s.minute, 0, 59, nil, "minute"},
+		{&s.hour, 0, 23, nil, "hour"},
+		{&s.dom, 1, 31, nil, "day of month"},
+		{&s.month, 1, 12, monthNames, "month"},
+		{&s.dow, 0, 7, dayNames, "day of week"},
+	} {
+		*f.dst, err = parseScheduleField(fields[0], f.min, f.max, f.names)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %v: %w", spec, f.name, err)
+		}
+
+		fields = fields[1:]
+	}
+
+	// both 0 and 7 are Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	s.domAny = strings.HasPrefix(strings.Fields(expanded)[2], "*")
+	s.dowAny = strings.HasPrefix(strings.Fields(expanded)[4], "*")
+
+	return s, nil
+}
+
+// parseScheduleField returns a bitset of the values in field.
+func parseScheduleField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				// month names start at 1, day names at 0
+				return i + min, nil
+			}
+		}
+
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+
+		if v < min || v > max {
+			return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+		}
+
+		return v, nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		var (
+			from, to int
+			err      error
+		)
+
+		switch {
+		case rng == "*":
+			from, to = min, max
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+
+			from, err = value(a)
+			if err != nil {
+				return 0, err
+			}
+
+			to, err = value(b)
+			if err != nil {
+				return 0, err
+			}
+
+			if to < from {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			from, err = value(rng)
+			if err != nil {
+				return 0, err
+			}
+
+			to = from
+			if hasStep {
+				to = max
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
+
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// matchesDay returns true if the schedule runs on the day of t.
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time after t the schedule runs. The zero time is
+// returned if there is none within the next five years (e.g. for February 30).
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	t.Parallel()
+
+	// 2024-03-13 is a Wednesday
+	now := time.Date(2024, 3, 13, 10, 30, 15, 0, time.UTC)
+
+	var tests = []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 13, 10, 31, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 3, 13, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 18 * * *", time.Date(2024, 3, 13, 18, 0, 0, 0, time.UTC)},
+		{"0 8 * * *", time.Date(2024, 3, 14, 8, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 13, 10, 45, 0, 0, time.UTC)},
+		{"0 8 * * mon", time.Date(2024, 3, 18, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1-5", time.Date(2024, 3, 14, 8, 0, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2024, 3, 17, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2024, 3, 17, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either day field matches if both are restricted
+		{"0 12 1,15 * fri", time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"30 6,18 * * *", time.Date(2024, 3, 13, 18, 30, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		s, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Errorf("%v: %v", test.spec, err)
+
+			continue
+		}
+
+		next := s.Next(now)
+		if !next.Equal(test.next) {
+			t.Errorf("%v: want %v, got %v", test.spec, test.next, next)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"foo * * * *",
+		"@yearly",
+	} {
+		_, err := ParseSchedule(spec)
+		if err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// DigestConfig configures a summary of events which is sent on a schedule
+// instead of one notification per event.
+type DigestConfig struct {
+	// Schedule is a cron-like schedule, e.g. "0 18 * * *" for every day at
+	// 18:00. An empty schedule disables the digest.
+	Schedule string `json:"schedule,omitempty"`
+
+	// Events lists the kinds of events collected for the digest, the
+	// default is filed, unknown and failed.
+	Events []string `json:"events,omitempty"`
+
+	// Notifiers receive the digest, the default is all notifiers.
+	Notifiers []string `json:"notifiers,omitempty"`
+
+	// Template overrides the message, it has access to the Summary.
+	Template *Template `json:"template,omitempty"`
+}
+
+// defaultDigestEvents are collected for the digest unless configured otherwise.
+var defaultDigestEvents = []string{EventFiled, EventUnknown, EventFailed}
+
+// defaultDigestTemplate is the message for the digest.
+var defaultDigestTemplate = Template{
+	Title: "Archive: {{.Count}} new events",
+	Text: `{{range .Filed}}{{.Correspondent}}: {{len .Files}} filed
+{{range .Files}}  - {{.Date}} {{.Title}}
+{{end}}{{end}}{{with .Unknown}}
+Waiting in unknown/: {{len .}}
+{{range .}}  - {{.Filename}}
+{{end}}{{end}}{{with .Failed}}
+Failed: {{len .}}
+{{range .}}  - {{.Path}}: {{.Error}}
+{{end}}{{end}}{{with .Other}}
+Other:
+{{range .}}  - {{.Title}}: {{.Text}}
+{{end}}{{end}}`,
+}
+
+// CorrespondentSummary lists the files filed for a correspondent.
+type CorrespondentSummary struct {
+	Correspondent string
+	Files         []Event
+}
+
+// Summary is the data available in the template for the digest.
+type Summary struct {
+	// Since is the time of the first event in the digest.
+	Since time.Time
+
+	// Count is the number of events.
+	Count int
+
+	// Filed lists the new files by correspondent.
+	Filed []CorrespondentSummary
+
+	// Unknown lists the files waiting in unknown/.
+	Unknown []database.File
+
+	// Failed lists the files which could not be processed.
+	Failed []Event
+
+	// Other contains the messages for all other events.
+	Other []Message
+}
+
+// Digest collects events until they are sent as a summary. The events are
+// stored in a file, so they are kept across restarts.
+type Digest struct {
+	Filename string
+	Schedule *Schedule
+	Events   []string
+
+	// Notifiers receive the digest, all notifiers are used if empty.
+	Notifiers []string
+
+	// Unknown returns the files currently waiting in unknown/. If nil, the
+	// files from unknown events are listed.
+	Unknown func() []database.File
+
+	template templates
+
+	mu      sync.Mutex
+	pending []Event
+}
+
+// NewDigest returns a digest configured by cfg, with the events stored in
+// filename loaded.
+func NewDigest(cfg DigestConfig, filename string) (*Digest, error) {
+	err := cfg.validate(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := cfg.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Digest{
+		Filename:  filename,
+		Schedule:  schedule,
+		Events:    cfg.Events,
+		Notifiers: cfg.Notifiers,
+		template:  tmpl,
+	}
+
+	if len(d.Events) == 0 {
+		d.Events = defaultDigestEvents
+	}
+
+	buf, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("load digest: %w", err)
+	}
+
+	err = json.Unmarshal(buf, &d.pending)
+	if err != nil {
+		return nil, fmt.Errorf("decode digest %v: %w", filename, err)
+	}
+
+	return d, nil
+}
+
+// validate checks the configuration, notifiers is nil if the names of the
+// notifiers are not known.
+func (cfg DigestConfig) validate(notifiers map[string]Notifier) error {
+	if cfg.Schedule == "" {
+		return errors.New("digest: no schedule")
+	}
+
+	schedule, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("digest: schedule %q never runs", cfg.Schedule)
+	}
+
+	for _, kind := range cfg.Events {
+		if !slices.Contains(EventKinds, kind) {
+			return fmt.Errorf("digest: unknown event %q", kind)
+		}
+	}
+
+	for _, name := range cfg.Notifiers {
+		if _, ok := notifiers[name]; notifiers != nil && !ok {
+			return fmt.Errorf("digest: unknown notifier %q", name)
+		}
+	}
+
+	_, err = cfg.parseTemplate()
+	if err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+
+	return nil
+}
+
+// parseTemplate parses the template for the digest.
+func (cfg DigestConfig) parseTemplate() (templates, error) {
+	tmpl := defaultDigestTemplate
+	if cfg.Template != nil {
+		tmpl = *cfg.Template
+	}
+
+	title, err := template.New("digest title").Parse(tmpl.Title)
+	if err != nil {
+		return templates{}, fmt.Errorf("template: %w", err)
+	}
+
+	text, err := template.New("digest text").Parse(tmpl.Text)
+	if err != nil {
+		return templates{}, fmt.Errorf("template: %w", err)
+	}
+
+	t := templates{title: title, text: text}
+
+	// catch references to unknown fields early
+	_, err = t.renderData(Summary{})
+	if err != nil {
+		return templates{}, fmt.Errorf("template: %w", err)
+	}
+
+	return t, nil
+}
+
+// Collects returns true if events of kind are collected for the digest.
+func (d *Digest) Collects(kind string) bool {
+	return slices.Contains(d.Events, kind)
+}
+
+// save writes the pending events to the file, the caller must hold d.mu.
+func (d *Digest) save() error {
+	buf, err := json.Marshal(d.pending)
+	if err != nil {
+		return fmt.Errorf("encode digest: %w", err)
+	}
+
+	// write to a temporary file first so the digest is never incomplete
+	err = os.WriteFile(d.Filename+".tmp", buf, 0600)
+	if err != nil {
+		return fmt.Errorf("save digest: %w", err)
+	}
+
+	err = os.Rename(d.Filename+".tmp", d.Filename)
+	if err != nil {
+		return fmt.Errorf("save digest: %w", err)
+	}
+
+	return nil
+}
+
+// Add stores ev until the next digest is sent.
+func (d *Digest) Add(ev Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = append(d.pending, ev)
+
+	return d.save()
+}
+
+// Pending returns the events collected for the next digest.
+func (d *Digest) Pending() []Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return slices.Clone(d.pending)
+}
+
+// remove removes the first n events after they have been sent.
+func (d *Digest) remove(n int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = slices.Delete(d.pending, 0, min(n, len(d.pending)))
+
+	return d.save()
+}
+
+// summarize returns the summary for events. For other events, the message is
+// rendered with templates.
+func (d *Digest) summarize(events []Event, templates map[string]templates) Summary {
+	s := Summary{Count: len(events)}
+
+	filed := make(map[string][]Event)
+
+	for _, ev := range events {
+		if s.Since.IsZero() || ev.Time.Before(s.Since) {
+			s.Since = ev.Time
+		}
+
+		switch ev.Kind {
+		case EventFiled:
+			filed[ev.Correspondent] = append(filed[ev.Correspondent], ev)
+		case EventUnknown:
+			if d.Unknown == nil {
+				s.Unknown = append(s.Unknown, ev.File)
+			}
+		case EventFailed:
+			s.Failed = append(s.Failed, ev)
+		default:
+			msg, err := templates[ev.Kind].render(ev)
+			if err != nil {
+				msg = Message{Title: ev.Kind, Text: err.Error()}
+			}
+
+			s.Other = append(s.Other, msg)
+		}
+	}
+
+	for correspondent, files := range filed {
+		s.Filed = append(s.Filed, CorrespondentSummary{Correspondent: correspondent, Files: files})
+	}
+
+	sort.Slice(s.Filed, func(i, j int) bool {
+		return strings.ToLower(s.Filed[i].Correspondent) < strings.ToLower(s.Filed[j].Correspondent)
+	})
+
+	if d.Unknown != nil {
+		s.Unknown = d.Unknown()
+
+		sort.Slice(s.Unknown, func(i, j int) bool {
+			return s.Unknown[i].Filename < s.Unknown[j].Filename
+		})
+	}
+
+	return s
+}
+
+// renderData executes the templates with data.
+func (t templates) renderData(data any) (Message, error) {
+	var title, text bytes.Buffer
+
+	err := t.title.Execute(&title, data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	err = t.text.Execute(&text, data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Title: strings.TrimSpace(title.String()),
+		Text:  strings.TrimSpace(text.String()),
+	}, nil
+}
+
+// SendDigest sends the summary of all pending events. Events are removed once
+// the digest was delivered to at least one notifier.
+func (d *Dispatcher) SendDigest(ctx context.Context) error {
+	events := d.Digest.Pending()
+	if len(events) == 0 {
+		d.log.Debug("no events for digest")
+
+		return nil
+	}
+
+	msg, err := d.Digest.template.renderData(d.Digest.summarize(events, d.templates))
+	if err != nil {
+		return fmt.Errorf("render digest: %w", err)
+	}
+
+	names := d.Digest.Notifiers
+	if len(names) == 0 {
+		for name := range d.Notifiers {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+	}
+
+	var (
+		errs []error
+		sent bool
+	)
+
+	for _, name := range names {
+		err := d.send(ctx, name, msg)
+		if err != nil {
+			d.log.WithField("notifier", name).Warnf("send digest: %v", err)
+			errs = append(errs, fmt.Errorf("%v: %w", name, err))
+
+			continue
+		}
+
+		sent = true
+	}
+
+	if !sent {
+		return fmt.Errorf("digest not sent: %w", errors.Join(errs...))
+	}
+
+	d.log.Infof("sent digest with %d events", len(events))
+
+	return d.Digest.remove(len(events))
+}
+
+// RunDigest sends the digest according to the schedule until ctx is cancelled.
+func (d *Dispatcher) RunDigest(ctx context.Context) error {
+	for {
+		next := d.Digest.Schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule %v never runs", d.Digest.Schedule)
+		}
+
+		d.log.Debugf("next digest at %v", next)
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil
+		case <-timer.C:
+		}
+
+		err := d.SendDigest(ctx)
+		if err != nil {
+			d.log.Warnf("%v", err)
+		}
+	}
+}
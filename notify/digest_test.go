@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// failing is a notifier which always returns an error.
+type failing struct{}
+
+func (failing) Notify(context.Context, Message) error {
+	return errors.New("test error")
+}
+
+func TestDigest(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "digest.json")
+	cfg := Config{
+		Digest: DigestConfig{Schedule: "0 18 * * *"},
+	}
+
+	newDispatcher := func(notifiers map[string]Notifier) *Dispatcher {
+		d, err := NewDispatcher(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d.Notifiers = notifiers
+
+		d.Digest, err = NewDigest(cfg.Digest, filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return d
+	}
+
+	rec := &recorder{}
+	d := newDispatcher(map[string]Notifier{"phone": rec})
+
+	for _, ev := range []Event{
+		{Kind: EventFiled, File: database.File{Correspondent: "Bank", Title: "Kontoauszug", Date: "02.01.2023"}},
+		{Kind: EventFiled, File: database.File{Correspondent: "Bank", Title: "Kontoauszug", Date: "02.02.2023"}},
+		{Kind: EventFiled, File: database.File{Correspondent: "Amazon", Title: "Rechnung", Date: "03.01.2023"}},
+		{Kind: EventUnknown, File: database.File{Correspondent: "unknown", Filename: "2023-01-04 scan.pdf"}},
+		{Kind: EventFailed, Path: "failed/foo.pdf", Error: "ocr failed"},
+	} {
+		err := d.Event(context.Background(), ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// events are collected, not sent
+	if len(rec.messages) != 0 {
+		t.Fatalf("messages sent before digest: %v", rec.messages)
+	}
+
+	// the events survive a restart, and are kept if sending fails
+	d = newDispatcher(map[string]Notifier{"broken": failing{}})
+
+	err := d.SendDigest(context.Background())
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	d = newDispatcher(map[string]Notifier{"phone": rec})
+
+	if len(d.Digest.Pending()) != 5 {
+		t.Fatalf("expected 5 pending events, got %d", len(d.Digest.Pending()))
+	}
+
+	err = d.SendDigest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.messages) != 1 {
+		t.Fatalf("expected one message, got %v", rec.messages)
+	}
+
+	want := Message{
+		Title: "Archive: 5 new events",
+		Text: strings.Join([]string{
+			"Amazon: 1 filed",
+			"  - 03.01.2023 Rechnung",
+			"Bank: 2 filed",
+			"  - 02.01.2023 Kontoauszug",
+			"  - 02.02.2023 Kontoauszug",
+			"",
+			"Waiting in unknown/: 1",
+			"  - 2023-01-04 scan.pdf",
+			"",
+			"Failed: 1",
+			"  - failed/foo.pdf: ocr failed",
+		}, "\n"),
+	}
+
+	if rec.messages[0] != want {
+		t.Errorf("wrong digest, want:\n%v\n%v\ngot:\n%v\n%v", want.Title, want.Text, rec.messages[0].Title, rec.messages[0].Text)
+	}
+
+	if len(d.Digest.Pending()) != 0 {
+		t.Errorf("events not removed after sending: %v", d.Digest.Pending())
+	}
+
+	// nothing to send
+	err = d.SendDigest(context.Background())
+	if err != nil || len(rec.messages) != 1 {
+		t.Errorf("unexpected digest without events: %v %v", err, rec.messages)
+	}
+}
@@ -0,0 +1,308 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// Kinds of events which trigger notifications.
+const (
+	EventFiled     = "filed"
+	EventUnknown   = "unknown"
+	EventFailed    = "failed"
+	EventDuplicate = "duplicate"
+	EventDiskFull  = "disk_full"
+	EventReview    = "review"
+	EventDue       = "due"
+	EventMissing   = "missing"
+)
+
+// EventKinds lists all kinds of events.
+var EventKinds = []string{EventFiled, EventUnknown, EventFailed, EventDuplicate, EventDiskFull, EventReview, EventDue, EventMissing}
+
+// Event is something the user is notified about. All fields of the file are
+// available in templates, e.g. {{.Title}} or {{.Correspondent}}.
+type Event struct {
+	database.File
+
+	Kind string
+	Time time.Time
+
+	// ID is the ID of the file in the archive.
+	ID string
+
+	// Path is the location of the file, e.g. in the failed directory.
+	Path string
+
+	// Error describes why processing failed.
+	Error string
+
+	// Reason describes why a file needs to be reviewed.
+	Reason string
+
+	// DaysLeft is the number of days until the due date, it is negative for
+	// overdue files.
+	DaysLeft int
+
+	// Cadence is the interval in which the correspondent sends documents,
+	// Expected the date (DD.MM.YYYY) the missing document was expected.
+	Cadence  string
+	Expected string
+
+	// Existing is the file already in the archive for duplicates.
+	Existing database.File
+
+	// Document is the location of the PDF file in the archive, Thumbnail
+	// the image of its first page. Both are empty if not available.
+	Document  string
+	Thumbnail string
+
+	// DiskFree is the free space in bytes, DiskFreePercent relative to the
+	// size of the file system.
+	DiskFree        uint64
+	DiskFreePercent float64
+}
+
+// Template configures the message sent for an event.
+type Template struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// defaultTemplates are used for events without a configured template.
+var defaultTemplates = map[string]Template{
+	EventFiled: {
+		Title: "Archive: new file",
+		Text:  "Filed {{.Title}} from {{.Date}} for {{.Correspondent}}{{with .Type}} as {{.}}{{end}}",
+	},
+	EventUnknown: {
+		Title: "Archive: unknown correspondent",
+		Text:  "Filed {{.Title}} from {{.Date}} to {{.Correspondent}}/, please sort it",
+	},
+	EventFailed: {
+		Title: "Archive: processing failed",
+		Text:  "Processing {{.Path}} failed: {{.Error}}",
+	},
+	EventDuplicate: {
+		Title: "Archive: duplicate file",
+		Text:  "{{.Title}} is already in the archive as {{.Existing.Correspondent}}/{{.Existing.Filename}}",
+	},
+	EventDiskFull: {
+		Title: "Archive: disk nearly full",
+		Text:  "Only {{printf \"%.1f\" .DiskFreePercent}}% of the disk is free",
+	},
+	EventReview: {
+		Title: "Archive: review pending",
+		Text:  "Please review {{.Correspondent}}/{{.Filename}}: {{.Reason}}",
+	},
+	EventDue: {
+		Title: "Archive: payment due",
+		Text: "{{.Title}} from {{.Correspondent}}{{with .Amount}} ({{.}}){{end}} is due on {{.DueDate}}" +
+			"{{if lt .DaysLeft 0}}, overdue{{end}}",
+	},
+	EventMissing: {
+		Title: "Archive: document missing",
+		Text: "No document from {{.Correspondent}} since {{.Existing.Date}} ({{.Existing.Title}}), " +
+			"expected {{.Cadence}} around {{.Expected}}",
+	},
+}
+
+// Route sends matching events to the listed notifiers. Empty conditions match
+// all events.
+type Route struct {
+	Events        []string `json:"events,omitempty"`
+	Correspondent string   `json:"correspondent,omitempty"`
+	Type          string   `json:"type,omitempty"`
+	Tag           string   `json:"tag,omitempty"`
+
+	Notifiers []string `json:"notifiers"`
+}
+
+// Matches returns true if the route applies to ev.
+func (r Route) Matches(ev Event) bool {
+	if len(r.Events) > 0 && !slices.Contains(r.Events, ev.Kind) {
+		return false
+	}
+
+	if r.Correspondent != "" && !strings.EqualFold(r.Correspondent, ev.Correspondent) {
+		return false
+	}
+
+	if r.Type != "" && !strings.EqualFold(r.Type, ev.Type) {
+		return false
+	}
+
+	if r.Tag != "" && !ev.HasTag(r.Tag) {
+		return false
+	}
+
+	return true
+}
+
+// templates is a parsed Template.
+type templates struct {
+	title, text *template.Template
+}
+
+// parseTemplates parses the configured templates, using the default for
+// events without a template.
+func parseTemplates(configured map[string]Template) (map[string]templates, error) {
+	res := make(map[string]templates, len(EventKinds))
+
+	for _, kind := range EventKinds {
+		tmpl, ok := configured[kind]
+		if !ok {
+			tmpl = defaultTemplates[kind]
+		}
+
+		title, err := template.New(kind + " title").Parse(tmpl.Title)
+		if err != nil {
+			return nil, fmt.Errorf("template for %v: %w", kind, err)
+		}
+
+		text, err := template.New(kind + " text").Parse(tmpl.Text)
+		if err != nil {
+			return nil, fmt.Errorf("template for %v: %w", kind, err)
+		}
+
+		t := templates{title: title, text: text}
+
+		// catch references to unknown fields early
+		_, err = t.render(Event{Kind: kind})
+		if err != nil {
+			return nil, fmt.Errorf("template for %v: %w", kind, err)
+		}
+
+		res[kind] = t
+	}
+
+	return res, nil
+}
+
+// render returns the message for ev.
+func (t templates) render(ev Event) (Message, error) {
+	return t.renderData(ev)
+}
+
+// validateEvents checks templates and routes.
+func (c Config) validateEvents(notifiers map[string]Notifier) error {
+	for kind := range c.Templates {
+		if !slices.Contains(EventKinds, kind) {
+			return fmt.Errorf("template for unknown event %q", kind)
+		}
+	}
+
+	for i, route := range c.Routes {
+		for _, kind := range route.Events {
+			if !slices.Contains(EventKinds, kind) {
+				return fmt.Errorf("route %d: unknown event %q", i+1, kind)
+			}
+		}
+
+		if len(route.Notifiers) == 0 {
+			return fmt.Errorf("route %d: no notifiers", i+1)
+		}
+
+		for _, name := range route.Notifiers {
+			if _, ok := notifiers[name]; !ok {
+				return fmt.Errorf("route %d: unknown notifier %q", i+1, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// recipients returns the names of the notifiers ev is sent to. Without
+// routes, all events are sent to all notifiers.
+func (d *Dispatcher) recipients(ev Event) []string {
+	var names []string
+
+	if len(d.Routes) == 0 {
+		for name := range d.Notifiers {
+			names = append(names, name)
+		}
+	}
+
+	for _, route := range d.Routes {
+		if !route.Matches(ev) {
+			continue
+		}
+
+		for _, name := range route.Notifiers {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Event renders the message for ev and sends it to the notifiers selected by
+// the routes. Events collected for the digest are stored instead. Errors are
+// logged and returned.
+func (d *Dispatcher) Event(ctx context.Context, ev Event) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	if d.Digest != nil && d.Digest.Collects(ev.Kind) {
+		err := d.Digest.Add(ev)
+		if err != nil {
+			d.log.Warnf("%v", err)
+		}
+
+		return err
+	}
+
+	t, ok := d.templates[ev.Kind]
+	if !ok {
+		return fmt.Errorf("unknown event %q", ev.Kind)
+	}
+
+	msg, err := t.render(ev)
+	if err != nil {
+		d.log.Warnf("render message for %v: %v", ev.Kind, err)
+
+		return fmt.Errorf("render message for %v: %w", ev.Kind, err)
+	}
+
+	names := d.recipients(ev)
+	if len(names) == 0 {
+		d.log.Debugf("no notifiers for event %v", ev.Kind)
+
+		return nil
+	}
+
+	var errs []error
+
+	for _, name := range names {
+		msg := msg
+
+		attachment, err := d.configs[name].attachment(ev)
+		if err != nil {
+			d.log.WithField("notifier", name).Infof("not attaching file: %v", err)
+		}
+
+		msg.Attachment = attachment
+
+		err = d.send(ctx, name, msg)
+		if err != nil {
+			d.log.WithField("notifier", name).Warnf("%v", err)
+			errs = append(errs, fmt.Errorf("%v: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
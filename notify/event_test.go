@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// recorder is a notifier which records all messages.
+type recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (r *recorder) Notify(_ context.Context, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = append(r.messages, msg)
+
+	return nil
+}
+
+func TestDispatcherEvent(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		Templates: map[string]Template{
+			EventFiled: {
+				Title: "{{.Correspondent}}",
+				Text:  "{{.Title}} ({{.Date}}){{range .Tags}} #{{.}}{{end}}",
+			},
+		},
+		Routes: []Route{
+			{Events: []string{EventFiled}, Notifiers: []string{"phone"}},
+			{Tag: "tax", Notifiers: []string{"mail"}},
+			{Events: []string{EventFailed, EventDiskFull}, Notifiers: []string{"phone", "mail"}},
+		},
+	}
+
+	phone, mail := &recorder{}, &recorder{}
+	notifiers := map[string]Notifier{"phone": phone, "mail": mail}
+
+	err := cfg.validateEvents(notifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := parseTemplates(cfg.Templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDispatcher(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Notifiers = notifiers
+	d.Routes = cfg.Routes
+	d.templates = templates
+
+	file := database.File{
+		Correspondent: "Bank",
+		Title:         "Kontoauszug",
+		Date:          "02.01.2023",
+		Tags:          []string{"tax"},
+	}
+
+	for _, ev := range []Event{
+		{Kind: EventFiled, File: file},
+		{Kind: EventFailed, Path: "failed/scan.pdf", Error: "ocr failed"},
+		{Kind: EventReview, File: database.File{Correspondent: "Bank", Filename: "foo.pdf"}, Reason: "no date found"},
+	} {
+		err = d.Event(context.Background(), ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wantPhone := []Message{
+		{Title: "Bank", Text: "Kontoauszug (02.01.2023) #tax"},
+		{Title: "Archive: processing failed", Text: "Processing failed/scan.pdf failed: ocr failed"},
+	}
+
+	if !reflect.DeepEqual(phone.messages, wantPhone) {
+		t.Errorf("phone: want %v, got %v", wantPhone, phone.messages)
+	}
+
+	// the review event is not routed anywhere
+	wantMail := []Message{wantPhone[0], wantPhone[1]}
+	if !reflect.DeepEqual(mail.messages, wantMail) {
+		t.Errorf("mail: want %v, got %v", wantMail, mail.messages)
+	}
+}
+
+func TestEventConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		cfg Config
+		err string
+	}{
+		{Config{Templates: map[string]Template{"foo": {}}}, `unknown event "foo"`},
+		{Config{Templates: map[string]Template{EventFiled: {Text: "{{.Foo}}"}}}, "can't evaluate field Foo"},
+		{Config{Templates: map[string]Template{EventFiled: {Text: "{{.Title"}}}, "unclosed action"},
+		{Config{Routes: []Route{{Events: []string{EventFiled}}}}, "no notifiers"},
+		{Config{Routes: []Route{{Notifiers: []string{"foo"}}}}, `unknown notifier "foo"`},
+		{Config{DiskMinFreePercent: 100}, "invalid disk_min_free_percent"},
+	}
+
+	for _, test := range tests {
+		err := test.cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%+v: expected error %q, got %v", test.cfg, test.err, err)
+		}
+	}
+}
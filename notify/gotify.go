@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("gotify", newGotify)
+}
+
+// GotifyOptions configures notifications via a Gotify server.
+type GotifyOptions struct {
+	URL      string `json:"url"`
+	Token    string `json:"token"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// Gotify sends messages to a Gotify server.
+type Gotify struct {
+	opts GotifyOptions
+}
+
+func newGotify(options json.RawMessage) (Notifier, error) {
+	var opts GotifyOptions
+
+	err := decodeOptions(options, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.URL == "" {
+		return nil, errors.New("url is missing")
+	}
+
+	if opts.Token == "" {
+		return nil, errors.New("token is missing")
+	}
+
+	return &Gotify{opts: opts}, nil
+}
+
+// Notify sends msg to the server.
+func (g *Gotify) Notify(ctx context.Context, msg Message) error {
+	header := make(http.Header)
+	header.Set("X-Gotify-Key", g.opts.Token)
+
+	body := struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority,omitempty"`
+	}{
+		Title:    msg.Title,
+		Message:  msg.Text,
+		Priority: g.opts.Priority,
+	}
+
+	return sendHTTP(ctx, http.MethodPost, strings.TrimSuffix(g.opts.URL, "/")+"/message", header, body, nil)
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// sendHTTP sends a request with body to url and checks the response status.
+// Values of type []byte are sent as is, everything else as JSON. If result is
+// not nil, the response is decoded into it.
+func sendHTTP(ctx context.Context, method, url string, header http.Header, body, result any) error {
+	buf, ok := body.([]byte)
+	if !ok {
+		var err error
+
+		buf, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+
+		header = header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+
+		header.Set("Content-Type", "application/json")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+
+		return fmt.Errorf("server returned %v: %v", res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if result != nil {
+		err = json.NewDecoder(res.Body).Decode(result)
+		if err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+
+	// read the rest of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	return nil
+}
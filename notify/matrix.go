@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	Register("matrix", newMatrix)
+}
+
+// MatrixOptions configures notifications sent to a Matrix room.
+type MatrixOptions struct {
+	Homeserver  string `json:"homeserver"`
+	AccessToken string `json:"access_token"`
+	RoomID      string `json:"room_id"`
+}
+
+// Matrix sends messages to a Matrix room.
+type Matrix struct {
+	opts MatrixOptions
+}
+
+func newMatrix(options json.RawMessage) (Notifier, error) {
+	var opts MatrixOptions
+
+	err := decodeOptions(options, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case opts.Homeserver == "":
+		return nil, errors.New("homeserver is missing")
+	case opts.AccessToken == "":
+		return nil, errors.New("access_token is missing")
+	case opts.RoomID == "":
+		return nil, errors.New("room_id is missing")
+	}
+
+	return &Matrix{opts: opts}, nil
+}
+
+// Notify sends msg as a text message to the room. An attachment is uploaded
+// and sent as a separate image or file message.
+func (m *Matrix) Notify(ctx context.Context, msg Message) error {
+	err := m.send(ctx, map[string]string{
+		"msgtype": "m.text",
+		"body":    msg.Title + "\n\n" + msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+
+	if msg.Attachment == nil {
+		return nil
+	}
+
+	uri, err := m.upload(ctx, msg.Attachment)
+	if err != nil {
+		return err
+	}
+
+	msgtype := "m.file"
+	if strings.HasPrefix(msg.Attachment.ContentType, "image/") {
+		msgtype = "m.image"
+	}
+
+	return m.send(ctx, map[string]any{
+		"msgtype": msgtype,
+		"body":    msg.Attachment.Filename,
+		"url":     uri,
+		"info": map[string]any{
+			"mimetype": msg.Attachment.ContentType,
+			"size":     len(msg.Attachment.Data),
+		},
+	})
+}
+
+// header returns the header for requests to the homeserver.
+func (m *Matrix) header() http.Header {
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+m.opts.AccessToken)
+
+	return header
+}
+
+// send sends an event with content to the room.
+func (m *Matrix) send(ctx context.Context, content any) error {
+	// the transaction ID makes retries of the same request idempotent
+	buf := make([]byte, 8)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return fmt.Errorf("generate transaction ID: %w", err)
+	}
+
+	target := fmt.Sprintf("%v/_matrix/client/v3/rooms/%v/send/m.room.message/nepomuk-%v",
+		strings.TrimSuffix(m.opts.Homeserver, "/"), url.PathEscape(m.opts.RoomID), hex.EncodeToString(buf))
+
+	return sendHTTP(ctx, http.MethodPut, target, m.header(), content, nil)
+}
+
+// upload stores attachment on the homeserver and returns its URI.
+func (m *Matrix) upload(ctx context.Context, attachment *Attachment) (string, error) {
+	target := fmt.Sprintf("%v/_matrix/media/v3/upload?filename=%v",
+		strings.TrimSuffix(m.opts.Homeserver, "/"), url.QueryEscape(attachment.Filename))
+
+	header := m.header()
+	header.Set("Content-Type", attachment.ContentType)
+
+	var res struct {
+		ContentURI string `json:"content_uri"`
+	}
+
+	err := sendHTTP(ctx, http.MethodPost, target, header, attachment.Data, &res)
+	if err != nil {
+		return "", fmt.Errorf("upload attachment: %w", err)
+	}
+
+	return res.ContentURI, nil
+}
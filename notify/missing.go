@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// MissingConfig configures alerts for recurring documents which have not
+// arrived in time.
+type MissingConfig struct {
+	// Enabled sends a "missing" event once for each expected document.
+	Enabled bool `json:"enabled"`
+
+	// Schedule is a cron-like schedule for checking the correspondents.
+	Schedule string `json:"schedule"`
+}
+
+// DefaultMissingConfig returns the default configuration, alerts are
+// disabled.
+func DefaultMissingConfig() MissingConfig {
+	return MissingConfig{
+		Schedule: "0 8 * * *",
+	}
+}
+
+func (cfg MissingConfig) validate() error {
+	_, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return fmt.Errorf("missing: %w", err)
+	}
+
+	return nil
+}
+
+// MissingEvent returns the event for a recurring document which has not
+// arrived.
+func MissingEvent(r database.Recurring, now time.Time) Event {
+	return Event{
+		Kind:     EventMissing,
+		Time:     now,
+		File:     database.File{Correspondent: r.Correspondent},
+		ID:       r.LastID,
+		Existing: r.Last,
+		Cadence:  string(r.Cadence),
+		Expected: r.Expected.Format("02.01.2006"),
+	}
+}
+
+// Missing sends notifications for recurring documents which are overdue.
+type Missing struct {
+	Config MissingConfig
+
+	// Recurring returns the correspondents which send documents regularly.
+	Recurring func() []database.Recurring
+}
+
+// RunMissing checks for missing documents according to the schedule until
+// ctx is cancelled. Each missing document is reported once while the
+// process is running.
+func (d *Dispatcher) RunMissing(ctx context.Context, m Missing) error {
+	schedule, err := ParseSchedule(m.Config.Schedule)
+	if err != nil {
+		return err
+	}
+
+	// reported contains the expected date for each correspondent which was
+	// already reported
+	reported := make(map[string]time.Time)
+
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule %v never runs", schedule)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil
+		case <-timer.C:
+		}
+
+		now := time.Now()
+
+		for _, r := range m.Recurring() {
+			if !r.Missing(now) || reported[r.Correspondent].Equal(r.Expected) {
+				continue
+			}
+
+			reported[r.Correspondent] = r.Expected
+
+			d.log.Infof("no document from %v since %v", r.Correspondent, r.Last.Date)
+
+			// errors are logged by Event
+			_ = d.Event(ctx, MissingEvent(r, now))
+		}
+	}
+}
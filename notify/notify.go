@@ -1,54 +1,278 @@
 package notify
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
+	"sort"
+	"sync"
+	"time"
 
-	"github.com/fd0/nepomuk/database"
-	"github.com/gregdel/pushover"
 	"github.com/sirupsen/logrus"
 )
 
+// Message is a notification sent to the user.
+type Message struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+
+	// Attachment is an optional file, e.g. a thumbnail of a document.
+	// Notifiers which do not support attachments ignore it.
+	Attachment *Attachment `json:"attachment,omitempty"`
+}
+
+// Notifier delivers messages, e.g. via push notifications or email.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Factory creates a notifier from its JSON options.
+type Factory func(options json.RawMessage) (Notifier, error)
+
 var (
-	token      = os.Getenv("NEPOMUK_PUSHOVER_TOKEN")
-	recipients = os.Getenv("NEPOMUK_PUSHOVER_RECIPIENTS")
+	registryMu sync.Mutex
+	registry   = make(map[string]Factory)
 )
 
-func Notify(logger logrus.FieldLogger, file database.File) {
-	log := logger.WithField("component", "database-watcher")
-	if token == "" {
-		log.Warn("no pushover token found, skipping notification")
+// Register makes a notifier type available for the configuration. It panics
+// if the type is registered twice.
+func Register(typ string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[typ]; ok {
+		panic(fmt.Sprintf("notifier type %q registered twice", typ))
+	}
+
+	registry[typ] = factory
+}
+
+// Types returns the names of all registered notifier types.
+func Types() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+
+	sort.Strings(types)
+
+	return types
+}
+
+// Config configures where notifications are sent.
+type Config struct {
+	Notifiers []NotifierConfig `json:"notifiers,omitempty"`
+
+	// Templates overrides the messages for the event kinds.
+	Templates map[string]Template `json:"templates,omitempty"`
+
+	// Routes selects the notifiers for events. Without routes, all events
+	// are sent to all notifiers.
+	Routes []Route `json:"routes,omitempty"`
+
+	// DiskMinFreePercent triggers a notification when less space is free on
+	// the disk holding the archive, zero disables the check.
+	DiskMinFreePercent float64 `json:"disk_min_free_percent"`
+
+	// Digest collects events and sends a summary on a schedule.
+	Digest DigestConfig `json:"digest"`
+
+	// Reminders notify about files with a due date.
+	Reminders RemindersConfig `json:"reminders"`
 
-		return
+	// Missing alerts about recurring documents which have not arrived.
+	Missing MissingConfig `json:"missing"`
+}
+
+// NotifierConfig configures a single notifier. The options depend on the type.
+type NotifierConfig struct {
+	// Name identifies the notifier, it defaults to the type.
+	Name    string          `json:"name,omitempty"`
+	Type    string          `json:"type"`
+	Options json.RawMessage `json:"options,omitempty"`
+
+	// Attach selects a file sent with notifications about documents, either
+	// "thumbnail" or "document". It is supported by pushover, matrix, smtp
+	// and webhook.
+	Attach string `json:"attach,omitempty"`
+
+	// MaxAttachmentSize is the size limit for attachments in bytes, larger
+	// files are not attached.
+	MaxAttachmentSize int64 `json:"max_attachment_size,omitempty"`
+}
+
+// name returns the name of the notifier.
+func (c NotifierConfig) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	return c.Type
+}
+
+// New creates the notifier described by cfg.
+func New(cfg NotifierConfig) (Notifier, error) {
+	registryMu.Lock()
+	factory, ok := registry[cfg.Type]
+	registryMu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
 	}
 
-	if recipients == "" {
-		log.Warn("no recipients found, skipping notification")
+	n, err := factory(cfg.Options)
+	if err != nil {
+		return nil, fmt.Errorf("notifier %v: %w", cfg.name(), err)
+	}
+
+	return n, nil
+}
 
-		return
+// Validate checks the configuration for errors.
+func (c Config) Validate() error {
+	notifiers, err := c.notifiers()
+	if err != nil {
+		return err
 	}
 
-	// Create a new pushover app with a token
-	app := pushover.New(token)
+	_, err = parseTemplates(c.Templates)
+	if err != nil {
+		return err
+	}
 
-	// Create the message to send
-	message := pushover.NewMessageWithTitle(
-		fmt.Sprintf("Archiver found new file %v for correspondent %v", file.Filename, file.Correspondent),
-		"Archive: new file",
-	)
+	if c.DiskMinFreePercent < 0 || c.DiskMinFreePercent >= 100 {
+		return fmt.Errorf("invalid disk_min_free_percent %v", c.DiskMinFreePercent)
+	}
 
-	// Create a new recipient
-	for _, r := range strings.Split(recipients, ",") {
-		recipient := pushover.NewRecipient(r)
+	if c.Reminders.Enabled {
+		err = c.Reminders.validate()
+		if err != nil {
+			return err
+		}
+	}
 
-		// Send the message to the recipient
-		response, err := app.SendMessage(message, recipient)
+	if c.Missing.Enabled {
+		err = c.Missing.validate()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "unable to send message: %v\n", err)
+			return err
 		}
+	}
 
-		// Print the response if you want
-		log.Printf("response from pushover: %v", response)
+	if c.Digest.Schedule != "" {
+		err = c.Digest.validate(notifiers)
+		if err != nil {
+			return err
+		}
 	}
+
+	return c.validateEvents(notifiers)
+}
+
+// notifiers creates all configured notifiers, indexed by name.
+func (c Config) notifiers() (map[string]Notifier, error) {
+	notifiers := make(map[string]Notifier, len(c.Notifiers))
+
+	for _, cfg := range c.Notifiers {
+		if _, ok := notifiers[cfg.name()]; ok {
+			return nil, fmt.Errorf("notifier name %q used more than once", cfg.name())
+		}
+
+		switch cfg.Attach {
+		case AttachNone, AttachThumbnail, AttachDocument:
+		default:
+			return nil, fmt.Errorf("notifier %v: invalid attach %q", cfg.name(), cfg.Attach)
+		}
+
+		if cfg.MaxAttachmentSize < 0 {
+			return nil, fmt.Errorf("notifier %v: invalid max_attachment_size %v", cfg.name(), cfg.MaxAttachmentSize)
+		}
+
+		n, err := New(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		notifiers[cfg.name()] = n
+	}
+
+	return notifiers, nil
+}
+
+// decodeOptions decodes the JSON options of a notifier into opts.
+func decodeOptions(options json.RawMessage, opts any) error {
+	if len(options) == 0 {
+		return nil
+	}
+
+	err := json.Unmarshal(options, opts)
+	if err != nil {
+		return fmt.Errorf("decode options: %w", err)
+	}
+
+	return nil
+}
+
+// sendTimeout is the time a notifier may take to deliver a message.
+const sendTimeout = time.Minute
+
+// Dispatcher sends messages for events to the configured notifiers.
+type Dispatcher struct {
+	Notifiers map[string]Notifier
+	Routes    []Route
+
+	// Digest collects events for a summary if set, see RunDigest.
+	Digest *Digest
+
+	templates map[string]templates
+
+	// configs contains the configuration for each notifier by name
+	configs map[string]NotifierConfig
+
+	log logrus.FieldLogger
+}
+
+// NewDispatcher creates the notifiers configured in cfg.
+func NewDispatcher(cfg Config) (*Dispatcher, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers, err := cfg.notifiers()
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := parseTemplates(cfg.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]NotifierConfig, len(cfg.Notifiers))
+	for _, n := range cfg.Notifiers {
+		configs[n.name()] = n
+	}
+
+	return &Dispatcher{
+		Notifiers: notifiers,
+		Routes:    cfg.Routes,
+		templates: templates,
+		configs:   configs,
+		log:       logrus.StandardLogger(),
+	}, nil
+}
+
+// SetLogger sets the logger the dispatcher will use.
+func (d *Dispatcher) SetLogger(logger logrus.FieldLogger) {
+	d.log = logger.WithField("component", "notify")
+}
+
+func (d *Dispatcher) send(ctx context.Context, name string, msg Message) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	return d.Notifiers[name].Notify(ctx, msg)
 }
@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gregdel/pushover"
+)
+
+// request is an HTTP request received by the test server.
+type request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// testServer returns a server which records all requests.
+func testServer(t *testing.T, response string, header http.Header) (*httptest.Server, <-chan request) {
+	ch := make(chan request, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		_ = req.ParseForm()
+
+		ch <- request{
+			Method: req.Method,
+			Path:   req.URL.EscapedPath(),
+			Header: req.Header,
+			Body:   string(body),
+		}
+
+		for name, values := range header {
+			res.Header()[name] = values
+		}
+
+		_, _ = io.WriteString(res, response)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, ch
+}
+
+func TestHTTPNotifiers(t *testing.T) {
+	t.Parallel()
+
+	msg := Message{Title: "new file", Text: "found 2023-01-02 foo.pdf"}
+
+	var tests = []struct {
+		typ     string
+		options func(url string) string
+		check   func(t *testing.T, req request)
+	}{
+		{
+			typ: "webhook",
+			options: func(url string) string {
+				return `{"url": "` + url + `/hook", "headers": {"X-Secret": "foo"}}`
+			},
+			check: func(t *testing.T, req request) {
+				var got Message
+
+				err := json.Unmarshal([]byte(req.Body), &got)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if req.Method != http.MethodPost || req.Path != "/hook" || got != msg || req.Header.Get("X-Secret") != "foo" {
+					t.Errorf("unexpected request %+v", req)
+				}
+			},
+		},
+		{
+			typ: "ntfy",
+			options: func(url string) string {
+				return `{"url": "` + url + `", "topic": "archive", "token": "secret"}`
+			},
+			check: func(t *testing.T, req request) {
+				if req.Path != "/archive" || req.Body != msg.Text ||
+					req.Header.Get("Title") != msg.Title ||
+					req.Header.Get("Authorization") != "Bearer secret" {
+					t.Errorf("unexpected request %+v", req)
+				}
+			},
+		},
+		{
+			typ: "gotify",
+			options: func(url string) string {
+				return `{"url": "` + url + `/", "token": "secret", "priority": 5}`
+			},
+			check: func(t *testing.T, req request) {
+				want := `{"title":"new file","message":"found 2023-01-02 foo.pdf","priority":5}`
+				if req.Path != "/message" || req.Body != want || req.Header.Get("X-Gotify-Key") != "secret" {
+					t.Errorf("unexpected request %+v", req)
+				}
+			},
+		},
+		{
+			typ: "matrix",
+			options: func(url string) string {
+				return `{"homeserver": "` + url + `", "access_token": "secret", "room_id": "!room:example.com"}`
+			},
+			check: func(t *testing.T, req request) {
+				if req.Method != http.MethodPut ||
+					!strings.HasPrefix(req.Path, "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/") ||
+					!strings.Contains(req.Body, `"body":"new file\n\nfound 2023-01-02 foo.pdf"`) ||
+					req.Header.Get("Authorization") != "Bearer secret" {
+					t.Errorf("unexpected request %+v", req)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.typ, func(t *testing.T) {
+			t.Parallel()
+
+			srv, requests := testServer(t, "{}", nil)
+
+			n, err := New(NotifierConfig{Type: test.typ, Options: json.RawMessage(test.options(srv.URL))})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = n.Notify(context.Background(), msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.check(t, <-requests)
+		})
+	}
+}
+
+func TestHTTPNotifierError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		http.Error(res, "invalid token", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	n, err := New(NotifierConfig{Type: "webhook", Options: json.RawMessage(`{"url": "` + srv.URL + `"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Notify(context.Background(), Message{})
+	if err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("expected error from server, got %v", err)
+	}
+}
+
+// TestPushover changes the global pushover.APIEndpoint, so it must not run in
+// parallel with other tests using pushover.
+func TestPushover(t *testing.T) {
+	header := http.Header{
+		"X-Limit-App-Limit":     []string{"7500"},
+		"X-Limit-App-Remaining": []string{"7499"},
+		"X-Limit-App-Reset":     []string{"1393653600"},
+	}
+
+	srv, requests := testServer(t, `{"status": 1, "request": "1234"}`, header)
+
+	endpoint := pushover.APIEndpoint
+	pushover.APIEndpoint = srv.URL
+
+	defer func() {
+		pushover.APIEndpoint = endpoint
+	}()
+
+	token := strings.Repeat("a", 30)
+
+	n, err := New(NotifierConfig{
+		Type:    "pushover",
+		Options: json.RawMessage(`{"token": "` + token + `", "recipients": ["` + strings.Repeat("u", 30) + `"]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Notify(context.Background(), Message{Title: "new file", Text: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if req.Path != "/messages.json" || !strings.Contains(req.Body, "title=new+file") || !strings.Contains(req.Body, "token="+token) {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		cfg string
+		err string
+	}{
+		{`{"notifiers": [{"type": "ntfy", "options": {"topic": "foo"}}]}`, ""},
+		{`{"notifiers": [{"type": "foo"}]}`, `unknown notifier type "foo"`},
+		{`{"notifiers": [{"type": "ntfy"}]}`, "topic is missing"},
+		{`{"notifiers": [{"type": "webhook", "options": {"url": "http://a"}}, {"type": "webhook", "options": {"url": "http://b"}}]}`, "used more than once"},
+		{`{"notifiers": [{"type": "smtp", "options": {"addr": "localhost", "from": "a@b", "to": ["c@d"]}}]}`, "invalid addr"},
+	}
+
+	for _, test := range tests {
+		var cfg Config
+
+		err := json.Unmarshal([]byte(test.cfg), &cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = cfg.Validate()
+
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", test.cfg, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%v: expected error %q, got %v", test.cfg, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("ntfy", newNtfy)
+}
+
+// NtfyOptions configures notifications via ntfy (https://ntfy.sh).
+type NtfyOptions struct {
+	// URL is the server, it defaults to https://ntfy.sh.
+	URL      string `json:"url,omitempty"`
+	Topic    string `json:"topic"`
+	Token    string `json:"token,omitempty"`
+	Priority string `json:"priority,omitempty"`
+}
+
+// Ntfy publishes messages to an ntfy topic.
+type Ntfy struct {
+	opts NtfyOptions
+}
+
+func newNtfy(options json.RawMessage) (Notifier, error) {
+	opts := NtfyOptions{URL: "https://ntfy.sh"}
+
+	err := decodeOptions(options, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Topic == "" {
+		return nil, errors.New("topic is missing")
+	}
+
+	return &Ntfy{opts: opts}, nil
+}
+
+// Notify publishes msg to the topic.
+func (n *Ntfy) Notify(ctx context.Context, msg Message) error {
+	header := make(http.Header)
+	header.Set("Title", msg.Title)
+
+	if n.opts.Priority != "" {
+		header.Set("Priority", n.opts.Priority)
+	}
+
+	if n.opts.Token != "" {
+		header.Set("Authorization", "Bearer "+n.opts.Token)
+	}
+
+	url := strings.TrimSuffix(n.opts.URL, "/") + "/" + n.opts.Topic
+
+	return sendHTTP(ctx, http.MethodPost, url, header, []byte(msg.Text), nil)
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gregdel/pushover"
+)
+
+func init() {
+	Register("pushover", newPushover)
+}
+
+// PushoverOptions configures notifications via https://pushover.net.
+type PushoverOptions struct {
+	Token      string   `json:"token"`
+	Recipients []string `json:"recipients"`
+}
+
+// Pushover sends push notifications via pushover.net.
+type Pushover struct {
+	app        *pushover.Pushover
+	recipients []*pushover.Recipient
+}
+
+func newPushover(options json.RawMessage) (Notifier, error) {
+	var opts PushoverOptions
+
+	err := decodeOptions(options, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Token == "" {
+		return nil, errors.New("token is missing")
+	}
+
+	if len(opts.Recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	p := &Pushover{app: pushover.New(opts.Token)}
+	for _, r := range opts.Recipients {
+		p.recipients = append(p.recipients, pushover.NewRecipient(r))
+	}
+
+	return p, nil
+}
+
+// Notify sends msg to all recipients.
+// Only images are attached.
+func (p *Pushover) Notify(ctx context.Context, msg Message) error {
+	var errs []error
+
+	for _, recipient := range p.recipients {
+		message := pushover.NewMessageWithTitle(msg.Text, msg.Title)
+
+		if msg.Attachment != nil && strings.HasPrefix(msg.Attachment.ContentType, "image/") {
+			// the attachment is read for each request
+			err := message.AddAttachment(bytes.NewReader(msg.Attachment.Data))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("pushover: %w", err))
+
+				continue
+			}
+		}
+
+		// the pushover client does not take a context, so run it in the background
+		errCh := make(chan error, 1)
+
+		go func() {
+			_, err := p.app.SendMessage(message, recipient)
+			errCh <- err
+		}()
+
+		select {
+		case err := <-errCh:
+			if err != nil {
+				errs = append(errs, fmt.Errorf("pushover: %w", err))
+			}
+		case <-ctx.Done():
+			return errors.Join(append(errs, fmt.Errorf("pushover: %w", ctx.Err()))...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// PushoverFromEnv returns the configuration of a Pushover notifier from the
+// environment variables NEPOMUK_PUSHOVER_TOKEN and
+// NEPOMUK_PUSHOVER_RECIPIENTS (comma separated), which were used before
+// notifiers could be configured.
+func PushoverFromEnv() (NotifierConfig, bool) {
+	token := os.Getenv("NEPOMUK_PUSHOVER_TOKEN")
+	recipients := os.Getenv("NEPOMUK_PUSHOVER_RECIPIENTS")
+
+	if token == "" || recipients == "" {
+		return NotifierConfig{}, false
+	}
+
+	options, err := json.Marshal(PushoverOptions{
+		Token:      token,
+		Recipients: strings.Split(recipients, ","),
+	})
+	if err != nil {
+		return NotifierConfig{}, false
+	}
+
+	return NotifierConfig{Type: "pushover", Options: options}, true
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+// RemindersConfig configures reminders for files with a due date.
+type RemindersConfig struct {
+	// Enabled sends reminders for files which are not paid.
+	Enabled bool `json:"enabled"`
+
+	// DaysBefore is the number of days before the due date the reminders
+	// start, they are repeated on every run until the file is tagged "paid".
+	DaysBefore int `json:"days_before"`
+
+	// Schedule is a cron-like schedule for checking the due dates.
+	Schedule string `json:"schedule"`
+}
+
+// DefaultRemindersConfig returns the default configuration, reminders are
+// disabled.
+func DefaultRemindersConfig() RemindersConfig {
+	return RemindersConfig{
+		DaysBefore: 3,
+		Schedule:   "0 9 * * *",
+	}
+}
+
+func (cfg RemindersConfig) validate() error {
+	if cfg.DaysBefore < 0 {
+		return errors.New("reminders: days_before must not be negative")
+	}
+
+	_, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return fmt.Errorf("reminders: %w", err)
+	}
+
+	return nil
+}
+
+// daysBetween returns the number of calendar days from a to b.
+func daysBetween(a, b time.Time) int {
+	a = time.Date(a.Year(), a.Month(), a.Day(), 12, 0, 0, 0, time.UTC)
+	b = time.Date(b.Year(), b.Month(), b.Day(), 12, 0, 0, 0, time.UTC)
+
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// DueEvents returns the events for all files which are due within days
+// after now (or overdue) and not paid, ordered by due date.
+func DueEvents(files map[string]database.File, now time.Time, days int) []Event {
+	var events []Event
+
+	for id, file := range files {
+		due, ok := file.Due(now.Location())
+		if !ok {
+			continue
+		}
+
+		left := daysBetween(now, due)
+		if left > days {
+			continue
+		}
+
+		events = append(events, Event{
+			Kind:     EventDue,
+			Time:     now,
+			ID:       id,
+			File:     file,
+			DaysLeft: left,
+		})
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].DaysLeft != events[j].DaysLeft {
+			return events[i].DaysLeft < events[j].DaysLeft
+		}
+
+		return events[i].ID < events[j].ID
+	})
+
+	return events
+}
+
+// Reminders sends notifications for files which are due soon.
+type Reminders struct {
+	Config RemindersConfig
+
+	// Files returns all files in the archive.
+	Files func() map[string]database.File
+
+	// Prepare is called for each event before it is sent, e.g. to add the
+	// location of the document. It may be nil.
+	Prepare func(*Event)
+}
+
+// RunReminders checks the due dates according to the schedule until ctx is
+// cancelled.
+func (d *Dispatcher) RunReminders(ctx context.Context, r Reminders) error {
+	schedule, err := ParseSchedule(r.Config.Schedule)
+	if err != nil {
+		return err
+	}
+
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule %v never runs", schedule)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil
+		case <-timer.C:
+		}
+
+		events := DueEvents(r.Files(), time.Now(), r.Config.DaysBefore)
+		d.log.Infof("%d files are due within %d days", len(events), r.Config.DaysBefore)
+
+		for _, ev := range events {
+			if r.Prepare != nil {
+				r.Prepare(&ev)
+			}
+
+			// errors are logged by Event
+			_ = d.Event(ctx, ev)
+		}
+	}
+}
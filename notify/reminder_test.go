@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+)
+
+func TestDueEvents(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]database.File{
+		"soon":    {Title: "Rechnung", Correspondent: "Amazon", DueDate: "12.03.2024", Amount: "49.90 EUR"},
+		"overdue": {Title: "Mahnung", Correspondent: "Stadtwerke", DueDate: "01.03.2024"},
+		"later":   {Title: "Beitrag", Correspondent: "Verein", DueDate: "01.04.2024"},
+		"paid":    {Title: "Rechnung", Correspondent: "Bank", DueDate: "11.03.2024", Tags: []string{"paid"}},
+		"none":    {Title: "Brief", Correspondent: "Bank"},
+	}
+
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	events := DueEvents(files, now, 3)
+	if len(events) != 2 {
+		t.Fatalf("want 2 events, got %+v", events)
+	}
+
+	if events[0].ID != "overdue" || events[0].DaysLeft != -9 {
+		t.Errorf("unexpected first event %+v", events[0])
+	}
+
+	if events[1].ID != "soon" || events[1].DaysLeft != 2 {
+		t.Errorf("unexpected second event %+v", events[1])
+	}
+
+	d, err := NewDispatcher(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recorder{}
+	d.Notifiers = map[string]Notifier{"phone": rec}
+
+	for _, ev := range events {
+		err = d.Event(context.Background(), ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(rec.messages) != 2 {
+		t.Fatalf("want 2 messages, got %d", len(rec.messages))
+	}
+
+	if !strings.Contains(rec.messages[0].Text, "overdue") {
+		t.Errorf("overdue file not marked: %q", rec.messages[0].Text)
+	}
+
+	want := "Rechnung from Amazon (49.90 EUR) is due on 12.03.2024"
+	if rec.messages[1].Text != want {
+		t.Errorf("want %q, got %q", want, rec.messages[1].Text)
+	}
+}
@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("smtp", newSMTP)
+}
+
+// SMTPOptions configures notifications via email.
+type SMTPOptions struct {
+	// Addr is the SMTP server as host:port.
+	Addr     string   `json:"addr"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// SMTP sends messages as email.
+type SMTP struct {
+	opts SMTPOptions
+}
+
+func newSMTP(options json.RawMessage) (Notifier, error) {
+	var opts SMTPOptions
+
+	err := decodeOptions(options, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case opts.Addr == "":
+		return nil, errors.New("addr is missing")
+	case opts.From == "":
+		return nil, errors.New("from is missing")
+	case len(opts.To) == 0:
+		return nil, errors.New("no recipients")
+	}
+
+	_, _, err = net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid addr: %w", err)
+	}
+
+	return &SMTP{opts: opts}, nil
+}
+
+// Notify sends msg as an email to all recipients. The connection uses
+// STARTTLS if the server supports it.
+func (s *SMTP) Notify(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+
+	if s.opts.Username != "" {
+		host, _, _ := net.SplitHostPort(s.opts.Addr)
+		auth = smtp.PlainAuth("", s.opts.Username, s.opts.Password, host)
+	}
+
+	// smtp.SendMail does not take a context, so run it in the background
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- smtp.SendMail(s.opts.Addr, auth, s.opts.From, s.opts.To, s.mail(msg, time.Now()))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("smtp: %w", ctx.Err())
+	}
+}
+
+// mail returns msg formatted as an email, with the attachment as a second
+// MIME part.
+func (s *SMTP) mail(msg Message, now time.Time) []byte {
+	buf := bytes.NewBuffer(nil)
+
+	fmt.Fprintf(buf, "From: %v\r\n", s.opts.From)
+	fmt.Fprintf(buf, "To: %v\r\n", strings.Join(s.opts.To, ", "))
+	fmt.Fprintf(buf, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(buf, "Date: %v\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	text := strings.ReplaceAll(strings.ReplaceAll(msg.Text, "\r\n", "\n"), "\n", "\r\n") + "\r\n"
+
+	if msg.Attachment == nil {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+		buf.WriteString("\r\n")
+		buf.WriteString(text)
+
+		return buf.Bytes()
+	}
+
+	w := multipart.NewWriter(buf)
+
+	fmt.Fprintf(buf, "Content-Type: multipart/mixed; boundary=%v\r\n", w.Boundary())
+	buf.WriteString("\r\n")
+
+	// writing to a bytes.Buffer does not fail
+	part, _ := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	_, _ = part.Write([]byte(text))
+
+	part, _ = w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {msg.Attachment.ContentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": msg.Attachment.Filename})},
+	})
+
+	// wrap lines as required for email
+	encoded := base64.StdEncoding.EncodeToString(msg.Attachment.Data)
+	for len(encoded) > 76 {
+		_, _ = part.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+
+	_, _ = part.Write([]byte(encoded + "\r\n"))
+
+	_ = w.Close()
+
+	return buf.Bytes()
+}
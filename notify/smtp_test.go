@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer accepts a single mail on a local port and returns the data.
+func smtpServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	ch := make(chan string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		rd := bufio.NewReader(conn)
+		reply := func(s string) {
+			_, _ = conn.Write([]byte(s + "\r\n"))
+		}
+
+		reply("220 localhost ESMTP test")
+
+		var data strings.Builder
+
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 go ahead")
+
+				for {
+					line, err := rd.ReadString('\n')
+					if err != nil {
+						return
+					}
+
+					if line == ".\r\n" {
+						break
+					}
+
+					data.WriteString(line)
+				}
+
+				ch <- data.String()
+
+				reply("250 ok")
+			case cmd == "QUIT":
+				reply("221 bye")
+
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), ch
+}
+
+func TestSMTP(t *testing.T) {
+	t.Parallel()
+
+	addr, mails := smtpServer(t)
+
+	n, err := New(NotifierConfig{
+		Type:    "smtp",
+		Options: json.RawMessage(`{"addr": "` + addr + `", "from": "nepomuk@example.com", "to": ["user@example.com"]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Notify(context.Background(), Message{Title: "Neue Datei für Bank", Text: "line 1\nline 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mail := <-mails
+
+	for _, want := range []string{
+		"From: nepomuk@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: =?utf-8?q?Neue_Datei_f=C3=BCr_Bank?=\r\n",
+		"\r\n\r\nline 1\r\nline 2\r\n",
+	} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("mail does not contain %q:\n%v", want, mail)
+		}
+	}
+}
+
+func TestSMTPAttachment(t *testing.T) {
+	t.Parallel()
+
+	s := &SMTP{opts: SMTPOptions{From: "nepomuk@example.com", To: []string{"user@example.com"}}}
+
+	mail := string(s.mail(Message{
+		Title: "new file",
+		Text:  "foo",
+		Attachment: &Attachment{
+			Filename:    "2023-01-02 foo.pdf",
+			ContentType: "application/pdf",
+			Data:        []byte("%PDF-1.4"),
+		},
+	}, time.Now()))
+
+	for _, want := range []string{
+		"Content-Type: multipart/mixed; boundary=",
+		"Content-Type: application/pdf\r\n",
+		`Content-Disposition: attachment; filename="2023-01-02 foo.pdf"`,
+		"JVBERi0xLjQ=\r\n",
+	} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("mail does not contain %q:\n%v", want, mail)
+		}
+	}
+}
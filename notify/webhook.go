@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+func init() {
+	Register("webhook", newWebhook)
+}
+
+// WebhookOptions configures a generic webhook.
+type WebhookOptions struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Webhook sends messages as JSON in a POST request.
+type Webhook struct {
+	url    string
+	header http.Header
+}
+
+func newWebhook(options json.RawMessage) (Notifier, error) {
+	var opts WebhookOptions
+
+	err := decodeOptions(options, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.URL == "" {
+		return nil, errors.New("url is missing")
+	}
+
+	w := &Webhook{url: opts.URL, header: make(http.Header)}
+	for name, value := range opts.Headers {
+		w.header.Set(name, value)
+	}
+
+	return w, nil
+}
+
+// Notify posts msg to the webhook.
+func (w *Webhook) Notify(ctx context.Context, msg Message) error {
+	return sendHTTP(ctx, http.MethodPost, w.url, w.header, msg, nil)
+}
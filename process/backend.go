@@ -0,0 +1,324 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Task describes a single post-processing run.
+type Task struct {
+	// Input is the PDF file to process.
+	Input string
+
+	// Output is the file the result is written to.
+	Output string
+
+	// Languages lists the tesseract languages (e.g. "deu") used for OCR.
+	Languages []string
+
+	// TempDir is the directory for temporary files, the default directory
+	// for temporary files is used if it is empty.
+	TempDir string
+}
+
+// Backend post-processes a PDF file, e.g. by running OCR.
+type Backend interface {
+	Run(ctx context.Context, log logrus.FieldLogger, task Task) error
+}
+
+// BackendConfig selects and configures a backend.
+type BackendConfig struct {
+	// Backend is the name of the backend: "ocrmypdf", "tesseract", "copy" or "command".
+	Backend string `json:"backend"`
+
+	// Languages is passed to the OCR program.
+	Languages []string `json:"languages,omitempty"`
+
+	// Command is the program run by the backend "command".
+	Command string `json:"command,omitempty"`
+
+	// Args replaces the default arguments of the backend. For the backend
+	// "command" each argument is a text/template, the fields Input, Output,
+	// OutputDir, Languages and TempDir are available.
+	Args []string `json:"args,omitempty"`
+}
+
+// Backend names.
+const (
+	BackendOCRmyPDF  = "ocrmypdf"
+	BackendTesseract = "tesseract"
+	BackendCopy      = "copy"
+	BackendCommand   = "command"
+)
+
+// NewBackend returns the backend described by cfg.
+func NewBackend(cfg BackendConfig) (Backend, error) {
+	switch cfg.Backend {
+	case BackendOCRmyPDF, "":
+		return OCRmyPDF{Args: cfg.Args}, nil
+	case BackendTesseract:
+		return Tesseract{Args: cfg.Args}, nil
+	case BackendCopy:
+		return Copy{}, nil
+	case BackendCommand:
+		if cfg.Command == "" {
+			return nil, errors.New("backend command: no command configured")
+		}
+
+		return NewCommand(cfg.Command, cfg.Args)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", cfg.Backend)
+	}
+}
+
+// runCommand runs cmd and includes stderr in the error message if it fails.
+func runCommand(cmd *exec.Cmd) error {
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("run %v: %w, stderr: %v", filepath.Base(cmd.Path), err, stderr.String())
+	}
+
+	return nil
+}
+
+// OCRmyPDF runs ocrmypdf on the file.
+type OCRmyPDF struct {
+	// Args replaces the default arguments if set.
+	Args []string
+}
+
+var defaultOCRmyPDFArgs = []string{
+	"--deskew", "--clean", "--clean-final",
+	"--skip-text", // skip OCR for pages which already have text
+	// "--remove-backgound", // try to make files smaller by removing the background
+}
+
+// Run runs ocrmypdf.
+func (b OCRmyPDF) Run(ctx context.Context, _ logrus.FieldLogger, task Task) error {
+	args := append([]string{}, b.Args...)
+	if len(args) == 0 {
+		args = append(args, defaultOCRmyPDFArgs...)
+	}
+
+	if len(task.Languages) > 0 {
+		args = append(args, "--language", strings.Join(task.Languages, "+"))
+	}
+
+	args = append(args, task.Input, task.Output)
+
+	err := runCommand(exec.CommandContext(ctx, "ocrmypdf", args...))
+
+	var exiterr *exec.ExitError
+	if errors.As(err, &exiterr) {
+		if exiterr.ExitCode() == 10 {
+			// docs say: A valid PDF was created, PDF/A conversion failed. The file will be available.
+			// we just ignore this error
+			err = nil
+		}
+	}
+
+	return err
+}
+
+// Tesseract renders all pages to images, runs tesseract on each page and
+// joins the resulting pages again.
+type Tesseract struct {
+	// Args are passed to tesseract in addition to the languages.
+	Args []string
+}
+
+// tesseractResolution is used to render pages before OCR.
+const tesseractResolution = "300"
+
+// Run runs tesseract on all pages of the file.
+func (b Tesseract) Run(ctx context.Context, log logrus.FieldLogger, task Task) error {
+	tempdir, err := os.MkdirTemp(task.TempDir, "nepomuk-tesseract-")
+	if err != nil {
+		return fmt.Errorf("create tempdir: %w", err)
+	}
+
+	defer func() {
+		err := os.RemoveAll(tempdir)
+		if err != nil {
+			log.Warnf("remove tempdir: %v", err)
+		}
+	}()
+
+	err = runCommand(exec.CommandContext(ctx, "pdftoppm", "-r", tesseractResolution, "-png",
+		task.Input, filepath.Join(tempdir, "page")))
+	if err != nil {
+		return err
+	}
+
+	images, err := filepath.Glob(filepath.Join(tempdir, "page-*.png"))
+	if err != nil {
+		return fmt.Errorf("list pages: %w", err)
+	}
+
+	if len(images) == 0 {
+		return fmt.Errorf("no pages found in %v", task.Input)
+	}
+
+	sort.Sort(Files(images))
+
+	pages := make([]string, 0, len(images))
+
+	for _, image := range images {
+		base := strings.TrimSuffix(image, ".png")
+
+		args := []string{image, base}
+		if len(task.Languages) > 0 {
+			args = append(args, "-l", strings.Join(task.Languages, "+"))
+		}
+
+		args = append(args, b.Args...)
+		args = append(args, "pdf")
+
+		err = runCommand(exec.CommandContext(ctx, "tesseract", args...))
+		if err != nil {
+			return err
+		}
+
+		pages = append(pages, base+".pdf")
+	}
+
+	if len(pages) == 1 {
+		return copyFile(pages[0], task.Output)
+	}
+
+	return runCommand(exec.CommandContext(ctx, "pdfunite", append(pages, task.Output)...))
+}
+
+// Copy does not run any OCR and just copies the file unmodified, e.g. for
+// PDFs which already contain text.
+type Copy struct{}
+
+// Run copies the file.
+func (Copy) Run(_ context.Context, _ logrus.FieldLogger, task Task) error {
+	return copyFile(task.Input, task.Output)
+}
+
+// copyFile copies the contents of src to the new file dest.
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		_ = out.Close()
+
+		return fmt.Errorf("copy %v -> %v: %w", src, dest, err)
+	}
+
+	err = out.Close()
+	if err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+
+	return nil
+}
+
+// Command runs an external program with templated arguments.
+type Command struct {
+	Command string
+	Args    []*template.Template
+}
+
+// NewCommand parses the templates in args and returns a Command backend.
+func NewCommand(command string, args []string) (Command, error) {
+	cmd := Command{Command: command}
+
+	for _, arg := range args {
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return Command{}, fmt.Errorf("parse argument %q: %w", arg, err)
+		}
+
+		cmd.Args = append(cmd.Args, tmpl)
+	}
+
+	return cmd, nil
+}
+
+// commandData is passed to the argument templates of Command.
+type commandData struct {
+	Input     string
+	Output    string
+	OutputDir string
+	Languages string
+	TempDir   string
+}
+
+// Run runs the command.
+func (b Command) Run(ctx context.Context, log logrus.FieldLogger, task Task) error {
+	tempdir, err := os.MkdirTemp(task.TempDir, "nepomuk-command-")
+	if err != nil {
+		return fmt.Errorf("create tempdir: %w", err)
+	}
+
+	defer func() {
+		err := os.RemoveAll(tempdir)
+		if err != nil {
+			log.Warnf("remove tempdir: %v", err)
+		}
+	}()
+
+	data := commandData{
+		Input:     task.Input,
+		Output:    task.Output,
+		OutputDir: filepath.Dir(task.Output),
+		Languages: strings.Join(task.Languages, "+"),
+		TempDir:   tempdir,
+	}
+
+	args := make([]string, 0, len(b.Args))
+
+	for _, tmpl := range b.Args {
+		var buf strings.Builder
+
+		err := tmpl.Execute(&buf, data)
+		if err != nil {
+			return fmt.Errorf("execute argument template: %w", err)
+		}
+
+		args = append(args, buf.String())
+	}
+
+	err = runCommand(exec.CommandContext(ctx, b.Command, args...))
+	if err != nil {
+		return err
+	}
+
+	// make sure the command created the output file
+	_, err = os.Stat(task.Output)
+	if err != nil {
+		return fmt.Errorf("command %v did not create output file: %w", b.Command, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package process
+
+import (
+	"image"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqrcode "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/oned"
+)
+
+// Barcodes returns the contents of all QR codes and barcodes (Code 128 and
+// Code 39) found in img. The decoding is done in pure Go.
+func Barcodes(img image.Image) []string {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+
+	var codes []string
+
+	// errors just mean that no code was found
+	results, _ := multiqrcode.NewQRCodeMultiReader().DecodeMultiple(bitmap, hints)
+	for _, result := range results {
+		codes = append(codes, result.GetText())
+	}
+
+	for _, reader := range []gozxing.Reader{oned.NewCode128Reader(), oned.NewCode39Reader()} {
+		result, err := reader.Decode(bitmap, hints)
+		if err == nil {
+			codes = append(codes, result.GetText())
+		}
+	}
+
+	return codes
+}
@@ -0,0 +1,145 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BlankPageConfig configures the detection and removal of blank pages.
+type BlankPageConfig struct {
+	// Remove enables the removal of blank pages.
+	Remove bool `json:"remove"`
+
+	// DryRun detects blank pages and only logs which pages would be removed,
+	// also if Remove is not set.
+	DryRun bool `json:"dry_run"`
+
+	// InkThreshold is the fraction of dark pixels below which a page is
+	// considered blank, e.g. 0.003 for 0.3%.
+	InkThreshold float64 `json:"ink_threshold"`
+
+	// Margin is the fraction of the width and height cropped at each side of
+	// the page before measuring, so that shadows at the edges and punch holes
+	// are ignored.
+	Margin float64 `json:"margin"`
+}
+
+// DefaultBlankPageConfig returns the default configuration, blank page
+// removal is disabled.
+func DefaultBlankPageConfig() BlankPageConfig {
+	return BlankPageConfig{
+		InkThreshold: 0.003,
+		Margin:       0.05,
+	}
+}
+
+// blankPageResolution is used to render pages for blank page detection.
+const blankPageResolution = 50
+
+// darkPixel is the gray value below which a pixel counts as ink.
+const darkPixel = 128
+
+// InkRatio returns the fraction of dark pixels in img, ignoring margin (a
+// fraction of width and height) at each side.
+func InkRatio(img image.Image, margin float64) float64 {
+	bounds := img.Bounds()
+	dx := int(float64(bounds.Dx()) * margin)
+	dy := int(float64(bounds.Dy()) * margin)
+	rect := image.Rect(bounds.Min.X+dx, bounds.Min.Y+dy, bounds.Max.X-dx, bounds.Max.Y-dy)
+
+	if rect.Empty() {
+		return 0
+	}
+
+	dark := 0
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			gray := color.GrayModel.Convert(img.At(x, y)).(color.Gray)
+			if gray.Y < darkPixel {
+				dark++
+			}
+		}
+	}
+
+	return float64(dark) / float64(rect.Dx()*rect.Dy())
+}
+
+// BlankPages returns the (1-based) numbers of the blank pages in filename and
+// the total number of pages. Temporary files are written to tempdir.
+func BlankPages(ctx context.Context, log logrus.FieldLogger, cfg BlankPageConfig, tempdir, filename string) ([]int, int, error) {
+	dir, err := os.MkdirTemp(tempdir, "blank-")
+	if err != nil {
+		return nil, 0, fmt.Errorf("create tempdir: %w", err)
+	}
+
+	images, err := renderPages(ctx, filename, dir, blankPageResolution)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var blank []int
+
+	for i, filename := range images {
+		img, err := loadImage(filename)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		ratio := InkRatio(img, cfg.Margin)
+		log.Debugf("page %d: %.3f%% ink", i+1, ratio*100)
+
+		if ratio < cfg.InkThreshold {
+			blank = append(blank, i+1)
+		}
+	}
+
+	return blank, len(images), nil
+}
+
+// removeBlankPages detects blank pages in filename and returns the name of a
+// file in tempdir without them, together with the number of pages removed.
+// If no page is removed (e.g. with cfg.DryRun), filename is returned.
+func removeBlankPages(ctx context.Context, log logrus.FieldLogger, cfg BlankPageConfig, tempdir, filename string) (string, int, error) {
+	blank, pages, err := BlankPages(ctx, log, cfg, tempdir, filename)
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch {
+	case len(blank) == 0:
+		log.Debugf("no blank pages found")
+
+		return filename, 0, nil
+	case len(blank) == pages:
+		log.Infof("all %d pages are blank, keeping the file as it is", pages)
+
+		return filename, 0, nil
+	case cfg.DryRun:
+		log.Infof("dry-run: would remove %d blank pages %v of %d", len(blank), blank, pages)
+
+		return filename, 0, nil
+	}
+
+	log.Infof("remove %d blank pages %v of %d", len(blank), blank, pages)
+
+	dir, err := os.MkdirTemp(tempdir, "nonblank-")
+	if err != nil {
+		return "", 0, fmt.Errorf("create tempdir: %w", err)
+	}
+
+	dest := filepath.Join(dir, filepath.Base(filename))
+
+	err = ExtractPages(ctx, tempdir, filename, dest, otherPages(pages, blank))
+	if err != nil {
+		return "", 0, fmt.Errorf("remove blank pages: %w", err)
+	}
+
+	return dest, len(blank), nil
+}
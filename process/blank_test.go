@@ -0,0 +1,46 @@
+package process
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestInkRatio(t *testing.T) {
+	t.Parallel()
+
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	if r := InkRatio(img, 0.1); r != 0 {
+		t.Errorf("white page: want ratio 0, got %v", r)
+	}
+
+	// a dark border is cropped by the margin
+	for i := 0; i < 100; i++ {
+		img.SetGray(i, 0, color.Gray{})
+		img.SetGray(0, i, color.Gray{})
+	}
+
+	if r := InkRatio(img, 0.1); r != 0 {
+		t.Errorf("page with dark border: want ratio 0, got %v", r)
+	}
+
+	if r := InkRatio(img, 0); r == 0 {
+		t.Errorf("page with dark border and no margin: want ratio > 0, got %v", r)
+	}
+
+	// a block of 16x10 dark pixels within the 80x80 pixels measured
+	for y := 40; y < 50; y++ {
+		for x := 40; x < 56; x++ {
+			img.SetGray(x, y, color.Gray{Y: 10})
+		}
+	}
+
+	want := 160.0 / 6400.0
+	if r := InkRatio(img, 0.1); r != want {
+		t.Errorf("page with text: want ratio %v, got %v", want, r)
+	}
+}
@@ -0,0 +1,116 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// minPageTextLength is the number of non-space characters each page of a
+// born-digital PDF must contain.
+const minPageTextLength = 20
+
+// minScanPixels is the size of an image (in pixels) from which on it is
+// considered a scanned page rather than e.g. a logo. A page of A5 scanned with
+// 100 dpi has roughly 580x830 pixels.
+const minScanPixels = 450000
+
+// pdfImageSizes returns the width and height of all images in filename.
+func pdfImageSizes(ctx context.Context, filename string) ([][2]int, error) {
+	buf := bytes.NewBuffer(nil)
+
+	cmd := exec.CommandContext(ctx, "pdfimages", "-list", filename)
+	cmd.Stdout = buf
+
+	err := runCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePDFImages(buf)
+}
+
+// parsePDFImages returns the width and height of all images listed in the
+// output of "pdfimages -list".
+func parsePDFImages(rd io.Reader) ([][2]int, error) {
+	var sizes [][2]int
+
+	sc := bufio.NewScanner(rd)
+	for sc.Scan() {
+		// the output starts with a header and a separator line:
+		// page   num  type   width height color comp bpc  enc interp  object ID x-ppi y-ppi size ratio
+		// --------------------------------------------------------------------------------------------
+		//    1     0 image    2480  3508  gray    1   8  jpeg   no         7  0   300   300  502K 5.9%
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue
+		}
+
+		width, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("parse pdfimages output %q: %w", sc.Text(), err)
+		}
+
+		height, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("parse pdfimages output %q: %w", sc.Text(), err)
+		}
+
+		sizes = append(sizes, [2]int{width, height})
+	}
+
+	return sizes, sc.Err()
+}
+
+// BornDigital returns true if filename was created digitally (e.g. an invoice
+// downloaded from a website) rather than scanned: Each page contains text and
+// the file contains no image large enough to be a scanned page. The reason
+// for the decision is returned as well.
+func BornDigital(ctx context.Context, filename string) (bool, string, error) {
+	text, err := pdfText(ctx, filename, 1, 0)
+	if err != nil {
+		return false, "", err
+	}
+
+	pages, reason := textPages(text)
+	if pages == 0 {
+		return false, reason, nil
+	}
+
+	sizes, err := pdfImageSizes(ctx, filename)
+	if err != nil {
+		return false, "", err
+	}
+
+	for _, size := range sizes {
+		if size[0]*size[1] >= minScanPixels {
+			return false, fmt.Sprintf("contains scanned image with %dx%d pixels", size[0], size[1]), nil
+		}
+	}
+
+	return true, fmt.Sprintf("%d pages with text and no scanned images", pages), nil
+}
+
+// textPages returns the number of pages in the output of pdftotext if all of
+// them contain at least minPageTextLength characters. Otherwise zero and the
+// reason are returned.
+func textPages(text []byte) (int, string) {
+	// pdftotext terminates each page with a form feed
+	pages := bytes.Split(bytes.TrimSuffix(text, []byte("\f")), []byte("\f"))
+	for i, page := range pages {
+		if textLength(page) < minPageTextLength {
+			return 0, fmt.Sprintf("page %d has no usable text", i+1)
+		}
+	}
+
+	return len(pages), ""
+}
@@ -0,0 +1,99 @@
+package process
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePDFImages(t *testing.T) {
+	t.Parallel()
+
+	const header = `page   num  type   width height color comp bpc  enc interp  object ID x-ppi y-ppi size ratio
+--------------------------------------------------------------------------------------------
+`
+
+	tests := []struct {
+		name   string
+		output string
+		want   [][2]int
+		err    bool
+	}{
+		{name: "empty"},
+		{name: "no-images", output: header},
+		{
+			name: "scan",
+			output: header +
+				"   1     0 image    2480  3508  gray    1   8  jpeg   no         7  0   300   300  502K 5.9%\n" +
+				"   2     1 image    2480  3508  gray    1   8  jpeg   no        12  0   300   300  498K 5.8%\n",
+			want: [][2]int{{2480, 3508}, {2480, 3508}},
+		},
+		{
+			name: "logo-and-mask",
+			output: header +
+				"   1     0 image     200    80  rgb     3   8  image  no         9  0    72    72 12.1K  26%\n" +
+				"   1     1 smask     200    80  gray    1   8  image  no         9  0    72    72  1.2K 7.5%\n",
+			want: [][2]int{{200, 80}, {200, 80}},
+		},
+		{
+			name:   "invalid-width",
+			output: header + "   1     0 image    wide  3508  gray    1   8  jpeg   no         7  0   300   300  502K 5.9%\n",
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sizes, err := parsePDFImages(strings.NewReader(test.output))
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected error, got %v", sizes)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(sizes, test.want) {
+				t.Errorf("want %v, got %v", test.want, sizes)
+			}
+		})
+	}
+}
+
+func TestTextPages(t *testing.T) {
+	t.Parallel()
+
+	page := "Rechnung Nr. 4711 vom 01.02.2023"
+	short := "Seite 2"
+
+	tests := []struct {
+		name  string
+		text  string
+		pages int
+	}{
+		{"one-page", page + "\f", 1},
+		{"two-pages", page + "\f" + page + "\f", 2},
+		{"empty", "", 0},
+		{"empty-page", page + "\f\f", 0},
+		{"short-page", page + "\f" + short + "\f", 0},
+		// only non-space characters are counted
+		{"spaces", strings.Repeat(" \n", minPageTextLength) + "\f", 0},
+		{"threshold", strings.Repeat("x", minPageTextLength) + "\f", 1},
+		{"below-threshold", strings.Repeat("x", minPageTextLength-1) + "\f", 0},
+	}
+
+	for _, test := range tests {
+		pages, reason := textPages([]byte(test.text))
+		if pages != test.pages {
+			t.Errorf("%v: want %d pages, got %d (%v)", test.name, test.pages, pages, reason)
+		}
+
+		if pages == 0 && reason == "" {
+			t.Errorf("%v: no reason returned", test.name)
+		}
+	}
+}
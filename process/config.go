@@ -0,0 +1,158 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// Config configures the post-processing of incoming files.
+type Config struct {
+	// Default is used for all files no rule matches.
+	Default BackendConfig `json:"default"`
+
+	// Rules select a different backend for some files, the first matching rule is used.
+	Rules []Rule `json:"rules,omitempty"`
+
+	// Language configures the detection of the document language.
+	Language LanguageConfig `json:"language"`
+
+	// DetectBornDigital enables the detection of PDFs which were created
+	// digitally. They are not post-processed and archived byte-for-byte, so
+	// e.g. digital signatures stay intact.
+	DetectBornDigital bool `json:"detect_born_digital"`
+
+	// Convert configures the conversion of images and office documents to PDF.
+	Convert ConvertConfig `json:"convert"`
+
+	// BlankPages configures the removal of blank pages before OCR.
+	BlankPages BlankPageConfig `json:"blank_pages"`
+
+	// Split configures splitting files at separator sheets.
+	Split SplitConfig `json:"split"`
+
+	// QRMetadata enables reading metadata (correspondent, title, date, tags)
+	// from a QR code on the first page.
+	QRMetadata bool `json:"qr_metadata"`
+
+	// Thumbnail configures the image of the first page rendered for each
+	// document, e.g. for notifications.
+	Thumbnail ThumbnailConfig `json:"thumbnail"`
+}
+
+// Rule selects the backend for files from an ingest source or with a name
+// matching a pattern. If both are set, both must match.
+type Rule struct {
+	// Source is the ingest source of the file, e.g. "webdav" or "incoming".
+	Source string `json:"source,omitempty"`
+
+	// Pattern is matched against the filename (see filepath.Match), e.g. "Receipt*".
+	Pattern string `json:"pattern,omitempty"`
+
+	BackendConfig
+}
+
+// DefaultConfig returns the default configuration: ocrmypdf with German.
+func DefaultConfig() Config {
+	return Config{
+		Default: BackendConfig{
+			Backend:   BackendOCRmyPDF,
+			Languages: []string{"deu"},
+		},
+		Language: LanguageConfig{
+			Languages: []string{"deu", "eng"},
+		},
+		Convert:    DefaultConvertConfig(),
+		BlankPages: DefaultBlankPageConfig(),
+		Split:      DefaultSplitConfig(),
+		Thumbnail:  DefaultThumbnailConfig(),
+	}
+}
+
+// Matches returns true if the rule applies to filename received from source.
+func (r Rule) Matches(source, filename string) bool {
+	if r.Source == "" && r.Pattern == "" {
+		return false
+	}
+
+	if r.Source != "" && r.Source != source {
+		return false
+	}
+
+	if r.Pattern != "" {
+		match, err := filepath.Match(r.Pattern, filepath.Base(filename))
+		if err != nil || !match {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validate checks the configuration for errors.
+func (c Config) Validate() error {
+	_, err := NewBackend(c.Default)
+	if err != nil {
+		return fmt.Errorf("default backend: %w", err)
+	}
+
+	for _, lang := range c.Language.Languages {
+		if _, ok := stopwords[lang]; !ok {
+			return fmt.Errorf("language detection: unsupported language %q", lang)
+		}
+	}
+
+	if c.BlankPages.Margin < 0 || c.BlankPages.Margin >= 0.5 {
+		return fmt.Errorf("blank pages: invalid margin %v", c.BlankPages.Margin)
+	}
+
+	if c.Thumbnail.Enabled && c.Thumbnail.Size <= 0 {
+		return fmt.Errorf("thumbnail: invalid size %v", c.Thumbnail.Size)
+	}
+
+	if c.Split.Enabled && c.Split.Text == "" && c.Split.Barcode == "" {
+		return errors.New("split: neither text nor barcode set")
+	}
+
+	for name, cmd := range map[string]CommandConfig{"images": c.Convert.Images, "office": c.Convert.Office} {
+		_, err := NewCommand(cmd.Command, cmd.Args)
+		if err != nil {
+			return fmt.Errorf("convert %v: %w", name, err)
+		}
+	}
+
+	for i, rule := range c.Rules {
+		if rule.Source == "" && rule.Pattern == "" {
+			return fmt.Errorf("rule %d: neither source nor pattern set", i)
+		}
+
+		_, err := filepath.Match(rule.Pattern, "")
+		if err != nil {
+			return fmt.Errorf("rule %d: invalid pattern %q: %w", i, rule.Pattern, err)
+		}
+
+		_, err = NewBackend(rule.BackendConfig)
+		if err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// BackendFor returns the backend configuration for filename received from
+// source. Rules without languages use the languages of the default backend.
+func (c Config) BackendFor(source, filename string) BackendConfig {
+	for _, rule := range c.Rules {
+		if rule.Matches(source, filename) {
+			cfg := rule.BackendConfig
+			if len(cfg.Languages) == 0 {
+				cfg.Languages = c.Default.Languages
+			}
+
+			return cfg
+		}
+	}
+
+	return c.Default
+}
@@ -0,0 +1,54 @@
+package process
+
+import "testing"
+
+func TestBackendFor(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.Rules = []Rule{
+		{
+			Pattern:       "Invoice*.pdf",
+			BackendConfig: BackendConfig{Backend: BackendCopy},
+		},
+		{
+			Source:        "webdav",
+			BackendConfig: BackendConfig{Backend: BackendTesseract, Languages: []string{"eng"}},
+		},
+		{
+			Source:        "incoming",
+			Pattern:       "*.tiff",
+			BackendConfig: BackendConfig{Backend: BackendCommand, Command: "true"},
+		},
+	}
+
+	err := cfg.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source, filename string
+		backend          string
+		language         string
+	}{
+		{"webdav", "/foo/Invoice 123.pdf", BackendCopy, "deu"},
+		{"incoming", "/foo/Invoice 123.pdf", BackendCopy, "deu"},
+		{"webdav", "/foo/scan.pdf", BackendTesseract, "eng"},
+		{"incoming", "/foo/scan.pdf", BackendOCRmyPDF, "deu"},
+		{"incoming", "/foo/scan.tiff", BackendCommand, "deu"},
+		{"webdav", "/foo/scan.tiff", BackendTesseract, "eng"},
+	}
+
+	for _, test := range tests {
+		got := cfg.BackendFor(test.source, test.filename)
+		if got.Backend != test.backend {
+			t.Errorf("BackendFor(%q, %q): want %v, got %v", test.source, test.filename, test.backend, got.Backend)
+		}
+
+		// rules without languages use the default languages
+		if len(got.Languages) != 1 || got.Languages[0] != test.language {
+			t.Errorf("BackendFor(%q, %q): want languages [%v], got %v", test.source, test.filename, test.language, got.Languages)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package process
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConvertConfig configures the conversion of files which are not PDF files.
+type ConvertConfig struct {
+	// Images is run for images (JPEG, PNG, GIF, TIFF) and must create a PDF
+	// file. The arguments are templates like for the backend "command", the
+	// field OutputDir contains the directory of Output.
+	Images CommandConfig `json:"images"`
+
+	// Office is run for office documents (OpenDocument, Microsoft Office, RTF),
+	// no conversion is done if Command is empty.
+	Office CommandConfig `json:"office"`
+
+	// KeepOriginals keeps the original file next to the PDF in the archive.
+	KeepOriginals bool `json:"keep_originals"`
+}
+
+// CommandConfig describes an external program.
+type CommandConfig struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// DefaultConvertConfig returns the default conversion config: images are
+// converted with img2pdf, office documents are not converted.
+func DefaultConvertConfig() ConvertConfig {
+	return ConvertConfig{
+		Images: CommandConfig{
+			Command: "img2pdf",
+			Args:    []string{"--output", "{{.Output}}", "{{.Input}}"},
+		},
+	}
+}
+
+// Content types returned by ContentType, which are handled specially.
+const (
+	ContentTypePDF     = "application/pdf"
+	ContentTypeTIFF    = "image/tiff"
+	ContentTypeOpenDoc = "application/vnd.oasis.opendocument"
+	ContentTypeOOXML   = "application/vnd.openxmlformats-officedocument"
+	ContentTypeOLE     = "application/x-ole-storage"
+	ContentTypeRTF     = "text/rtf"
+	contentTypeZIP     = "application/zip"
+)
+
+// contentTypeSniffLength is the number of bytes read to detect the content type.
+const contentTypeSniffLength = 512
+
+// ContentType returns the content type of filename, it is detected from the
+// contents of the file and not from the extension.
+func ContentType(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("open: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	buf := make([]byte, contentTypeSniffLength)
+
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read: %w", err)
+	}
+
+	buf = buf[:n]
+
+	switch {
+	case bytes.HasPrefix(buf, []byte("II*\x00")), bytes.HasPrefix(buf, []byte("MM\x00*")):
+		return ContentTypeTIFF, nil
+	case bytes.HasPrefix(buf, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")):
+		return ContentTypeOLE, nil
+	case bytes.HasPrefix(buf, []byte(`{\rtf`)):
+		return ContentTypeRTF, nil
+	}
+
+	ct, _, _ := strings.Cut(http.DetectContentType(buf), ";")
+	if ct == contentTypeZIP {
+		return zipContentType(f)
+	}
+
+	return ct, nil
+}
+
+// zipContentType inspects a ZIP file and detects office documents.
+func zipContentType(f *os.File) (string, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("stat: %w", err)
+	}
+
+	rd, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return contentTypeZIP, nil
+	}
+
+	for _, file := range rd.File {
+		switch file.Name {
+		case "mimetype":
+			// OpenDocument files contain the content type in the first file
+			r, err := file.Open()
+			if err != nil {
+				return contentTypeZIP, nil
+			}
+
+			buf, err := io.ReadAll(io.LimitReader(r, 200))
+			_ = r.Close()
+
+			if err == nil && strings.HasPrefix(string(buf), ContentTypeOpenDoc) {
+				return ContentTypeOpenDoc, nil
+			}
+		case "[Content_Types].xml":
+			return ContentTypeOOXML, nil
+		}
+	}
+
+	return contentTypeZIP, nil
+}
+
+// isImage returns true for the image types which are converted to PDF.
+func isImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", ContentTypeTIFF:
+		return true
+	}
+
+	return false
+}
+
+// isOffice returns true for office documents.
+func isOffice(contentType string) bool {
+	switch contentType {
+	case ContentTypeOpenDoc, ContentTypeOOXML, ContentTypeOLE, ContentTypeRTF:
+		return true
+	}
+
+	return false
+}
+
+// ErrUnsupportedContentType is returned for files which cannot be converted to PDF.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Convert converts filename to a PDF file in tempdir if it is not a PDF file
+// already. It returns the name of the PDF file, which is filename itself for
+// PDF files.
+func Convert(ctx context.Context, log logrus.FieldLogger, cfg ConvertConfig, tempdir, filename string) (string, error) {
+	contentType, err := ContentType(filename)
+	if err != nil {
+		return "", err
+	}
+
+	var converter CommandConfig
+
+	switch {
+	case contentType == ContentTypePDF:
+		return filename, nil
+	case isImage(contentType):
+		converter = cfg.Images
+	case isOffice(contentType) && cfg.Office.Command != "":
+		converter = cfg.Office
+	default:
+		return "", fmt.Errorf("%w: %v", ErrUnsupportedContentType, contentType)
+	}
+
+	log.Infof("convert %v to PDF with %v", contentType, converter.Command)
+
+	cmd, err := NewCommand(converter.Command, converter.Args)
+	if err != nil {
+		return "", err
+	}
+
+	outdir, err := os.MkdirTemp(tempdir, "convert-")
+	if err != nil {
+		return "", fmt.Errorf("create tempdir: %w", err)
+	}
+
+	name := filepath.Base(filename)
+	name = strings.TrimSuffix(name, filepath.Ext(name)) + ".pdf"
+
+	task := Task{
+		Input:  filename,
+		Output: filepath.Join(outdir, name),
+	}
+
+	err = cmd.Run(ctx, log, task)
+	if err != nil {
+		return "", fmt.Errorf("convert: %w", err)
+	}
+
+	return task.Output, nil
+}
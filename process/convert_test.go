@@ -0,0 +1,83 @@
+package process
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func zipFile(t testing.TB, files map[string]string) []byte {
+	buf := bytes.NewBuffer(nil)
+	wr := zip.NewWriter(buf)
+
+	for name, content := range files {
+		f, err := wr.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := wr.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestContentType(t *testing.T) {
+	t.Parallel()
+
+	pngImage := bytes.NewBuffer(nil)
+
+	err := png.Encode(pngImage, image.NewGray(image.Rect(0, 0, 10, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		// the extension must not matter
+		{"scan.jpg", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), ContentTypePDF},
+		{"photo.pdf", pngImage.Bytes(), "image/png"},
+		{"scan.tif", []byte("II*\x00\x08\x00\x00\x00"), ContentTypeTIFF},
+		{"scan.tif", []byte("MM\x00*\x00\x00\x00\x08"), ContentTypeTIFF},
+		{"letter.odt", zipFile(t, map[string]string{"mimetype": "application/vnd.oasis.opendocument.text"}), ContentTypeOpenDoc},
+		{"letter.docx", zipFile(t, map[string]string{"[Content_Types].xml": "<Types/>"}), ContentTypeOOXML},
+		{"archive.zip", zipFile(t, map[string]string{"foo.txt": "foo"}), "application/zip"},
+		{"letter.rtf", []byte(`{\rtf1\ansi foo}`), ContentTypeRTF},
+		{"notes.txt", []byte("just some text"), "text/plain"},
+	}
+
+	tempdir := t.TempDir()
+
+	for _, test := range tests {
+		filename := filepath.Join(tempdir, test.name)
+
+		err := os.WriteFile(filename, test.data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ct, err := ContentType(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ct != test.contentType {
+			t.Errorf("%v: want content type %v, got %v", test.name, test.contentType, ct)
+		}
+	}
+}
@@ -1,13 +1,9 @@
 package process
 
 import (
-	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 
 	"github.com/sirupsen/logrus"
 )
@@ -36,9 +32,9 @@ func (f Files) Swap(i, j int) {
 	f[i], f[j] = f[j], f[i]
 }
 
-// PostProcess runs OCR and optimizations on filename. On success, the file is
-// written to targetDir.
-func PostProcess(ctx context.Context, log logrus.FieldLogger, targetDir, filename string) (string, error) {
+// PostProcess runs the backend on filename. On success, the file is written to
+// dest. Temporary files are created in tempdir.
+func PostProcess(ctx context.Context, log logrus.FieldLogger, cfg BackendConfig, tempdir, filename, dest string) (string, error) {
 	fi, err := os.Lstat(filename)
 	if err != nil {
 		return "", fmt.Errorf("stat: %w", err)
@@ -55,32 +51,27 @@ func PostProcess(ctx context.Context, log logrus.FieldLogger, targetDir, filenam
 		return "", nil
 	}
 
-	dest := filepath.Join(targetDir, filepath.Base(filename))
-
-	stderr := bytes.NewBuffer(nil)
-
-	cmd := exec.CommandContext(ctx,
-		"ocrmypdf",
-		"--deskew", "--clean", "--clean-final",
-		"--language", "deu", // use Germany by default
-		"--skip-text", // skip OCR for pages which already have text
-		// "--remove-backgound", // try to make files smaller by removing the background
-		filename, dest)
-	cmd.Stderr = stderr
+	backend, err := NewBackend(cfg)
+	if err != nil {
+		return "", err
+	}
 
-	err = cmd.Run()
+	// remove leftovers from an earlier attempt, dest is unique for each job
+	err = os.Remove(dest)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("remove old output file: %w", err)
+	}
 
-	var exiterr *exec.ExitError
-	if errors.As(err, &exiterr) {
-		if exiterr.ExitCode() == 10 {
-			// docs say: A valid PDF was created, PDF/A conversion failed. The file will be available.
-			// we just ignore this error
-			err = nil
-		}
+	task := Task{
+		Input:     filename,
+		Output:    dest,
+		Languages: cfg.Languages,
+		TempDir:   tempdir,
 	}
 
+	err = backend.Run(ctx, log, task)
 	if err != nil {
-		return "", fmt.Errorf("run ocrmypdf: %w, stderr: %v", err, stderr.String())
+		return "", fmt.Errorf("backend %v: %w", cfg.Backend, err)
 	}
 
 	return dest, nil
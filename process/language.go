@@ -0,0 +1,177 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LanguageConfig configures the language detection.
+type LanguageConfig struct {
+	// Detect enables language detection, the detected language replaces the
+	// languages configured for the backend.
+	Detect bool `json:"detect"`
+
+	// Languages lists the tesseract languages which may be detected.
+	Languages []string `json:"languages,omitempty"`
+}
+
+// stopwords contains frequent short words for each supported language, keyed
+// by the tesseract language code.
+var stopwords = map[string][]string{
+	"deu": {"der", "die", "das", "und", "ist", "nicht", "mit", "sie", "den", "von", "zu", "ein", "eine",
+		"auf", "für", "im", "dem", "des", "sich", "wir", "ihr", "ihre", "bei", "oder", "auch", "wird", "werden"},
+	"eng": {"the", "and", "of", "to", "is", "in", "that", "for", "you", "your", "with", "this", "on",
+		"are", "be", "we", "our", "by", "from", "will", "have", "has", "not", "or", "please"},
+	"fra": {"le", "la", "les", "et", "des", "est", "une", "un", "du", "pour", "vous", "votre", "dans",
+		"que", "qui", "sur", "avec", "pas", "nous", "au", "aux", "ce", "cette", "sont"},
+	"spa": {"el", "los", "las", "y", "es", "una", "del", "por", "para", "con", "que", "su", "sus",
+		"usted", "como", "pero", "está", "este", "esta", "al"},
+	"ita": {"il", "di", "che", "è", "e", "per", "una", "della", "del", "con", "non", "sono", "gli",
+		"alla", "nel", "questo", "questa", "suo", "sua"},
+	"nld": {"de", "het", "een", "en", "van", "is", "dat", "niet", "met", "voor", "zijn", "op", "u",
+		"uw", "wij", "ook", "aan", "bij", "worden", "wordt"},
+}
+
+// minLanguageMatches is the number of stop words which must be found before a
+// language is detected.
+const minLanguageMatches = 5
+
+// DetectLanguage returns the language from allowed (tesseract language codes)
+// which matches text best. The empty string is returned if no language could be
+// detected.
+func DetectLanguage(text []byte, allowed []string) string {
+	counts := make(map[string]int, len(allowed))
+	words := make(map[string][]string)
+
+	for _, lang := range allowed {
+		for _, word := range stopwords[lang] {
+			words[word] = append(words[word], lang)
+		}
+	}
+
+	fields := bytes.FieldsFunc(bytes.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	for _, field := range fields {
+		for _, lang := range words[string(field)] {
+			counts[lang]++
+		}
+	}
+
+	best := ""
+	for _, lang := range allowed {
+		if counts[lang] < minLanguageMatches {
+			continue
+		}
+
+		if best == "" || counts[lang] > counts[best] {
+			best = lang
+		}
+	}
+
+	return best
+}
+
+// minTextLength is the number of non-space characters a PDF must contain to
+// consider its text layer usable.
+const minTextLength = 100
+
+// textLength returns the number of non-space characters in text.
+func textLength(text []byte) int {
+	n := 0
+
+	for _, r := range string(text) {
+		if !unicode.IsSpace(r) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// pdfText returns the text of the pages first to last of filename. If last is
+// zero, all pages starting with first are returned.
+func pdfText(ctx context.Context, filename string, first, last int) ([]byte, error) {
+	args := []string{"-f", fmt.Sprint(first)}
+	if last > 0 {
+		args = append(args, "-l", fmt.Sprint(last))
+	}
+
+	args = append(args, filename, "-")
+
+	buf := bytes.NewBuffer(nil)
+
+	cmd := exec.CommandContext(ctx, "pdftotext", args...)
+	cmd.Stdout = buf
+
+	err := runCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ocrFirstPage runs a quick OCR pass on the first page of filename with all languages.
+func ocrFirstPage(ctx context.Context, log logrus.FieldLogger, filename string, languages []string) ([]byte, error) {
+	tempdir, err := os.MkdirTemp("", "nepomuk-language-")
+	if err != nil {
+		return nil, fmt.Errorf("create tempdir: %w", err)
+	}
+
+	defer func() {
+		err := os.RemoveAll(tempdir)
+		if err != nil {
+			log.Warnf("remove tempdir: %v", err)
+		}
+	}()
+
+	image := filepath.Join(tempdir, "page")
+
+	err = runCommand(exec.CommandContext(ctx, "pdftoppm", "-r", "150", "-png", "-singlefile",
+		"-f", "1", "-l", "1", filename, image))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	cmd := exec.CommandContext(ctx, "tesseract", image+".png", "-", "-l", strings.Join(languages, "+"))
+	cmd.Stdout = buf
+
+	err = runCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// detectFileLanguage detects the language of filename. If the file has a text
+// layer it is used, otherwise the first page is run through OCR.
+func detectFileLanguage(ctx context.Context, log logrus.FieldLogger, filename string, allowed []string) (string, error) {
+	text, err := pdfText(ctx, filename, 1, 0)
+	if err != nil {
+		return "", err
+	}
+
+	if textLength(text) < minTextLength {
+		log.Debugf("no usable text layer, run OCR on first page for language detection")
+
+		text, err = ocrFirstPage(ctx, log, filename, allowed)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return DetectLanguage(text, allowed), nil
+}
@@ -0,0 +1,35 @@
+package process
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	t.Parallel()
+
+	allowed := []string{"deu", "eng", "fra"}
+
+	tests := []struct {
+		text string
+		lang string
+	}{
+		{"Sehr geehrte Damen und Herren, die Rechnung für den Monat ist mit dieser Post bei Ihnen, " +
+			"bitte überweisen Sie den Betrag auf das Konto der Firma.", "deu"},
+		{"Dear customer, thank you for your order. Please find the invoice for this month attached, " +
+			"the amount will be charged to the card on file.", "eng"},
+		{"Madame, Monsieur, nous vous remercions pour votre commande. Vous trouverez la facture dans " +
+			"cette lettre, le montant est à payer avec le formulaire.", "fra"},
+		{"Rechnung 12345 EUR 23,45", ""},
+	}
+
+	for _, test := range tests {
+		lang := DetectLanguage([]byte(test.text), allowed)
+		if lang != test.lang {
+			t.Errorf("DetectLanguage(%q): want %q, got %q", test.text, test.lang, lang)
+		}
+	}
+
+	// languages which are not allowed must not be detected
+	lang := DetectLanguage([]byte(tests[2].text), []string{"deu", "eng"})
+	if lang != "" {
+		t.Errorf("detected language %q which is not allowed", lang)
+	}
+}
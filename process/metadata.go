@@ -0,0 +1,168 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+	"github.com/sirupsen/logrus"
+)
+
+// MetadataPrefix starts the content of QR codes which contain metadata.
+const MetadataPrefix = "nepomuk:"
+
+// Metadata is read from a QR code on the first page of a document, it
+// overrides the data extracted from the text.
+type Metadata struct {
+	Correspondent string
+	Title         string
+	Date          string
+	Tags          []string
+	DueDate       string
+	Amount        string
+}
+
+// ErrNoMetadata is returned by ParseMetadata if the content does not start with MetadataPrefix.
+var ErrNoMetadata = errors.New("no metadata")
+
+// ParseMetadata parses the content of a QR code like
+// "nepomuk:correspondent=Finanzamt;title=Steuerbescheid;tags=tax,2023".
+// Invoices may contain "due=2024-03-01;amount=49.90 EUR".
+// Values may be percent-encoded, e.g. to contain a semicolon.
+func ParseMetadata(content string) (Metadata, error) {
+	content, ok := strings.CutPrefix(content, MetadataPrefix)
+	if !ok {
+		return Metadata{}, ErrNoMetadata
+	}
+
+	var md Metadata
+
+	for _, field := range strings.Split(content, ";") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Metadata{}, fmt.Errorf("invalid field %q", field)
+		}
+
+		value, err := url.PathUnescape(value)
+		if err != nil {
+			return Metadata{}, fmt.Errorf("invalid value for %v: %w", key, err)
+		}
+
+		value = strings.TrimSpace(value)
+
+		// correspondent and title are used for file and directory names
+		if strings.ContainsRune(value, '/') || value == "." || value == ".." {
+			return Metadata{}, fmt.Errorf("invalid value %q for %v", value, key)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "correspondent":
+			md.Correspondent = value
+		case "title":
+			md.Title = value
+		case "date":
+			_, err := time.Parse("02.01.2006", value)
+			if err != nil {
+				return Metadata{}, fmt.Errorf("invalid date %q, want DD.MM.YYYY", value)
+			}
+
+			md.Date = value
+		case "tags":
+			for _, tag := range strings.Split(value, ",") {
+				tag = strings.TrimSpace(tag)
+				if tag != "" {
+					md.Tags = append(md.Tags, tag)
+				}
+			}
+		case "due":
+			md.DueDate, err = database.ParseDueDate(value)
+			if err != nil {
+				return Metadata{}, err
+			}
+		case "amount":
+			md.Amount = value
+		default:
+			return Metadata{}, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	return md, nil
+}
+
+// Apply sets the fields of md which are not empty in file, tags are added.
+func (md Metadata) Apply(file *database.File) {
+	if md.Correspondent != "" {
+		file.Correspondent = md.Correspondent
+	}
+
+	if md.Title != "" {
+		file.Title = md.Title
+	}
+
+	if md.Date != "" {
+		file.Date = md.Date
+	}
+
+	if md.DueDate != "" {
+		file.DueDate = md.DueDate
+	}
+
+	if md.Amount != "" {
+		file.Amount = md.Amount
+	}
+
+	file.Tags = database.NormalizeTags(append(file.Tags, md.Tags...))
+}
+
+// metadataResolution is used to render the first page for finding QR codes with metadata.
+const metadataResolution = 150
+
+// FindMetadata looks for a QR code with metadata on the first page of
+// filename. If none is found, ErrNoMetadata is returned.
+func FindMetadata(ctx context.Context, log logrus.FieldLogger, tempdir, filename string) (Metadata, error) {
+	dir, err := os.MkdirTemp(tempdir, "metadata-")
+	if err != nil {
+		return Metadata{}, fmt.Errorf("create tempdir: %w", err)
+	}
+
+	image := filepath.Join(dir, "page")
+
+	err = runCommand(exec.CommandContext(ctx, "pdftoppm", "-gray", "-png", "-singlefile",
+		"-r", fmt.Sprint(metadataResolution), "-f", "1", "-l", "1", filename, image))
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	img, err := loadImage(image + ".png")
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	for _, code := range Barcodes(img) {
+		md, err := ParseMetadata(code)
+		if errors.Is(err, ErrNoMetadata) {
+			continue
+		}
+
+		if err != nil {
+			log.Warnf("ignore QR code %q: %v", code, err)
+
+			continue
+		}
+
+		return md, nil
+	}
+
+	return Metadata{}, ErrNoMetadata
+}
@@ -0,0 +1,66 @@
+package process
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		content string
+		want    Metadata
+		err     bool
+	}{
+		{
+			content: "nepomuk:correspondent=Finanzamt;title=Steuerbescheid;tags=tax",
+			want:    Metadata{Correspondent: "Finanzamt", Title: "Steuerbescheid", Tags: []string{"tax"}},
+		},
+		{
+			content: "nepomuk:title=Rechnung%3B Mai;tags=invoice, 2023,;",
+			want:    Metadata{Title: "Rechnung; Mai", Tags: []string{"invoice", "2023"}},
+		},
+		{
+			content: "nepomuk:title=Steuerbescheid;date=15.03.2023",
+			want:    Metadata{Title: "Steuerbescheid", Date: "15.03.2023"},
+		},
+		{
+			content: "nepomuk:title=Rechnung;due=2024-03-01;amount=49.90 EUR",
+			want:    Metadata{Title: "Rechnung", DueDate: "01.03.2024", Amount: "49.90 EUR"},
+		},
+		{content: "nepomuk:"},
+		{content: "nepomuk:date=2023-03-15", err: true},
+		{content: "nepomuk:due=tomorrow", err: true},
+		{content: "nepomuk:correspondent=../etc", err: true},
+		{content: "nepomuk:foo=bar", err: true},
+		{content: "nepomuk:title", err: true},
+	}
+
+	for _, test := range tests {
+		md, err := ParseMetadata(test.content)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %+v", test.content, md)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.content, err)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(md, test.want) {
+			t.Errorf("%q: want %+v, got %+v", test.content, test.want, md)
+		}
+	}
+
+	_, err := ParseMetadata("https://example.com")
+	if !errors.Is(err, ErrNoMetadata) {
+		t.Errorf("want ErrNoMetadata for other QR codes, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// renderPages renders the pages of filename as grayscale PNG images with
+// resolution into dir. The sorted list of images (one for each page) is returned.
+func renderPages(ctx context.Context, filename, dir string, resolution int) ([]string, error) {
+	err := runCommand(exec.CommandContext(ctx, "pdftoppm", "-gray", "-png",
+		"-r", fmt.Sprint(resolution), filename, filepath.Join(dir, "page")))
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, fmt.Errorf("list pages: %w", err)
+	}
+
+	sort.Sort(Files(images))
+
+	return images, nil
+}
+
+// loadImage decodes the PNG image in filename.
+func loadImage(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open image: %w", err)
+	}
+
+	img, err := png.Decode(f)
+	_ = f.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("decode image %v: %w", filename, err)
+	}
+
+	return img, nil
+}
+
+// ExtractPages writes the pages (1-based) of filename to dest.
+func ExtractPages(ctx context.Context, tempdir, filename, dest string, pages []int) error {
+	dir, err := os.MkdirTemp(tempdir, "pages-")
+	if err != nil {
+		return fmt.Errorf("create tempdir: %w", err)
+	}
+
+	err = runCommand(exec.CommandContext(ctx, "pdfseparate", filename, filepath.Join(dir, "page-%d.pdf")))
+	if err != nil {
+		return err
+	}
+
+	files := make([]string, 0, len(pages))
+	for _, page := range pages {
+		files = append(files, filepath.Join(dir, fmt.Sprintf("page-%d.pdf", page)))
+	}
+
+	if len(files) == 1 {
+		return copyFile(files[0], dest)
+	}
+
+	return runCommand(exec.CommandContext(ctx, "pdfunite", append(files, dest)...))
+}
+
+// otherPages returns the page numbers from 1 to pages which are not in list.
+func otherPages(pages int, list []int) []int {
+	skip := make(map[int]struct{}, len(list))
+	for _, page := range list {
+		skip[page] = struct{}{}
+	}
+
+	var res []int
+
+	for page := 1; page <= pages; page++ {
+		if _, ok := skip[page]; !ok {
+			res = append(res, page)
+		}
+	}
+
+	return res
+}
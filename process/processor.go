@@ -2,9 +2,14 @@ package process
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/fd0/nepomuk/queue"
 	"github.com/sirupsen/logrus"
 )
 
@@ -13,9 +18,20 @@ type Processor struct {
 	ProcessedDir string
 	TempDir      string
 
-	log logrus.FieldLogger
+	// OriginalsDir holds original files which were converted to PDF until they
+	// are moved into the archive.
+	OriginalsDir string
+
+	// ThumbnailDir receives the thumbnails of processed files.
+	ThumbnailDir string
+
+	// Config selects the post-processing backend for a file.
+	Config Config
 
-	OnFileProcessed func(string)
+	// Queue receives the documents split from a file.
+	Queue *queue.Queue
+
+	log logrus.FieldLogger
 }
 
 // SetLogger updates the logger to use.
@@ -23,48 +39,220 @@ func (p *Processor) SetLogger(logger logrus.FieldLogger) {
 	p.log = logger.WithField("component", "processor")
 }
 
-// processFile runs post processing for a single file. On success, the source
-// file is removed and the filename of the processed file (within ProcessedDir)
-// is returned.
-func (p *Processor) processFile(ctx context.Context, filename string) (string, error) {
-	log := p.log.WithField("filename", filename)
+// ProcessFile runs post processing for the file of job. On success, the
+// source file is removed and the filename of the processed file (within
+// ProcessedDir) is returned. Files which are skipped yield an empty filename.
+func (p *Processor) ProcessFile(ctx context.Context, job *queue.Job) (string, error) {
+	log := p.log.WithField("filename", job.Filename)
+
+	fi, err := os.Lstat(job.Filename)
+	if err != nil {
+		return "", fmt.Errorf("stat: %w", err)
+	}
+
+	// ignore files of size zero
+	if fi.Size() == 0 {
+		log.Infof("ignore empty file")
+
+		return "", p.removeSource(job.Filename)
+	}
+
+	tempdir, err := os.MkdirTemp(p.TempDir, "nepomuk-process-")
+	if err != nil {
+		return "", fmt.Errorf("create tempdir: %w", err)
+	}
+
+	defer func() {
+		err := os.RemoveAll(tempdir)
+		if err != nil {
+			log.Warnf("remove tempdir: %v", err)
+		}
+	}()
 
-	log.Infof("start post-process")
+	filename, err := Convert(ctx, log, p.Config.Convert, tempdir, job.Filename)
+	if errors.Is(err, ErrUnsupportedContentType) {
+		return "", fmt.Errorf("%w: %w", queue.ErrPermanent, err)
+	}
 
-	processed, err := PostProcess(ctx, p.log, p.ProcessedDir, filename)
+	if err != nil {
+		return "", err
+	}
+
+	converted := filename != job.Filename
+
+	// split the file at separator sheets, unless it is a part already
+	if p.Config.Split.Enabled && job.Part == 0 {
+		parts, err := Split(ctx, log, p.Config.Split, tempdir, filename)
+		if err != nil {
+			return "", fmt.Errorf("split: %w", err)
+		}
+
+		if len(parts) > 0 {
+			err = p.enqueueParts(job, parts)
+			if err != nil {
+				return "", err
+			}
+
+			return "", p.removeSource(job.Filename)
+		}
+	}
+
+	if p.Config.QRMetadata {
+		md, err := FindMetadata(ctx, log, tempdir, filename)
+		switch {
+		case errors.Is(err, ErrNoMetadata):
+			log.Debugf("no QR code with metadata found")
+		case err != nil:
+			return "", fmt.Errorf("find metadata: %w", err)
+		default:
+			log.Infof("found metadata in QR code: %+v", md)
+
+			md.Apply(&job.File)
+		}
+	}
+
+	backend := p.Config.BackendFor(job.Source, job.Filename)
+
+	if p.Config.DetectBornDigital {
+		bornDigital, reason, err := BornDigital(ctx, filename)
+		if err != nil {
+			return "", fmt.Errorf("detect born-digital PDF: %w", err)
+		}
+
+		job.File.BornDigital = bornDigital
+
+		if bornDigital {
+			log.Infof("file is born-digital (%v), skipping OCR and keeping the original file", reason)
+
+			backend = BackendConfig{Backend: BackendCopy}
+		} else {
+			log.Debugf("file is not born-digital: %v", reason)
+		}
+	}
+
+	// with DryRun, blank pages are detected and logged, but the file is not modified
+	if (p.Config.BlankPages.Remove || p.Config.BlankPages.DryRun) && !job.File.BornDigital {
+		filename, job.File.BlankPagesRemoved, err = removeBlankPages(ctx, log, p.Config.BlankPages, tempdir, filename)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if p.Config.Language.Detect {
+		lang, err := detectFileLanguage(ctx, log, filename, p.Config.Language.Languages)
+		if err != nil {
+			return "", fmt.Errorf("detect language: %w", err)
+		}
+
+		if lang != "" {
+			log.Infof("detected language %v", lang)
+
+			backend.Languages = []string{lang}
+			job.File.Language = lang
+		} else {
+			log.Infof("unable to detect language, using %v", backend.Languages)
+		}
+	}
+
+	log.Infof("start post-process with backend %v", backend.Backend)
+
+	// name the processed file after the job, so files with the same name do
+	// not overwrite each other
+	processed := filepath.Join(p.ProcessedDir, job.ID+"-"+filepath.Base(filename))
+
+	processed, err = PostProcess(ctx, p.log, backend, p.TempDir, filename, processed)
 	if err != nil {
 		return "", fmt.Errorf("post-process: %w", err)
 	}
 
 	log.Infof("post-process done")
 
-	err = os.Remove(filename)
+	if p.Config.Thumbnail.Enabled {
+		thumbnail := filepath.Join(p.ThumbnailDir, job.ID+".jpg")
+
+		// the thumbnail is optional, so errors are not fatal
+		err = Thumbnail(ctx, p.Config.Thumbnail, processed, thumbnail)
+		if err != nil {
+			log.Warnf("render thumbnail: %v", err)
+		} else {
+			job.Thumbnail = thumbnail
+		}
+	}
+
+	// keep the original file if it was converted
+	if converted && p.Config.Convert.KeepOriginals {
+		original := filepath.Join(p.OriginalsDir, job.ID+filepath.Ext(job.Filename))
+
+		err = os.Rename(job.Filename, original)
+		if err != nil {
+			return "", fmt.Errorf("keep original: %w", err)
+		}
+
+		job.Original = original
+
+		return processed, nil
+	}
+
+	err = p.removeSource(job.Filename)
 	if err != nil {
-		return "", fmt.Errorf("remove source %v failed: %w", filename, err)
+		return "", err
 	}
 
 	return processed, nil
 }
 
-func (p *Processor) Run(ctx context.Context, newFiles <-chan string) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case filename := <-newFiles:
-			processedFile, err := p.processFile(ctx, filename)
-			if err != nil {
-				p.log.WithField("filename", filename).Warnf("process failed: %v", err)
+// enqueueParts moves the documents split from the file of job next to it and
+// adds them to the queue, so they are processed as separate documents.
+func (p *Processor) enqueueParts(job *queue.Job, parts []string) error {
+	dir := filepath.Dir(job.Filename)
 
-				continue
-			}
+	for i, part := range parts {
+		// the job ID makes the name unique
+		filename := filepath.Join(dir, strings.TrimSuffix(filepath.Base(part), ".pdf")+"-"+job.ID+".pdf")
 
-			// skip files that cannot be processed right now
-			if processedFile == "" {
-				continue
-			}
+		// parts added before an earlier attempt failed are already in the queue
+		if slices.Contains(job.Parts, filename) {
+			continue
+		}
+
+		// copy to a hidden file first, the watcher for incoming/ ignores it
+		tempfile := filepath.Join(dir, "."+filepath.Base(filename))
 
-			p.OnFileProcessed(processedFile)
+		err := os.Remove(tempfile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove old part: %w", err)
+		}
+
+		err = copyFile(part, tempfile)
+		if err != nil {
+			return err
+		}
+
+		newJob := queue.Job{
+			Filename: filename,
+			Stage:    queue.StageProcess,
+			Source:   job.Source,
+			Part:     i + 1,
+			File:     job.File,
+		}
+
+		err = p.Queue.AddPart(job, newJob, func() error {
+			return os.Rename(tempfile, newJob.Filename)
+		})
+		if err != nil {
+			return fmt.Errorf("add part %d to queue: %w", i+1, err)
 		}
 	}
+
+	return nil
+}
+
+// removeSource removes the source file after processing.
+func (p *Processor) removeSource(filename string) error {
+	err := os.Remove(filename)
+	if err != nil {
+		return fmt.Errorf("remove source %v failed: %w", filename, err)
+	}
+
+	return nil
 }
@@ -0,0 +1,175 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SplitConfig configures splitting a scanned stack of documents at separator sheets.
+type SplitConfig struct {
+	// Enabled enables splitting documents at separator sheets.
+	Enabled bool `json:"enabled"`
+
+	// Text marks a separator sheet if a page contains it (case insensitive).
+	// This only works for files which already have a text layer.
+	Text string `json:"text,omitempty"`
+
+	// Barcode marks a separator sheet if a page contains a QR code or barcode
+	// (Code 128 or Code 39) with exactly this content.
+	Barcode string `json:"barcode,omitempty"`
+}
+
+// DefaultSplitMarker is the default text and barcode content of separator sheets.
+const DefaultSplitMarker = "NEPOMUK-SPLIT"
+
+// DefaultSplitConfig returns the default configuration, splitting is disabled.
+func DefaultSplitConfig() SplitConfig {
+	return SplitConfig{
+		Text:    DefaultSplitMarker,
+		Barcode: DefaultSplitMarker,
+	}
+}
+
+// splitResolution is used to render pages for finding barcodes on separator sheets.
+const splitResolution = 150
+
+// SeparatorPages returns the (1-based) numbers of the separator pages in
+// filename and the total number of pages.
+func SeparatorPages(ctx context.Context, cfg SplitConfig, tempdir, filename string) ([]int, int, error) {
+	dir, err := os.MkdirTemp(tempdir, "split-")
+	if err != nil {
+		return nil, 0, fmt.Errorf("create tempdir: %w", err)
+	}
+
+	var texts [][]byte
+
+	if cfg.Text != "" {
+		text, err := pdfText(ctx, filename, 1, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// pdftotext terminates each page with a form feed
+		texts = bytes.Split(bytes.ToLower(text), []byte("\f"))
+	}
+
+	images, err := renderPages(ctx, filename, dir, splitResolution)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var separators []int
+
+	for i, image := range images {
+		if i < len(texts) && bytes.Contains(texts[i], []byte(strings.ToLower(cfg.Text))) {
+			separators = append(separators, i+1)
+
+			continue
+		}
+
+		if cfg.Barcode == "" {
+			continue
+		}
+
+		img, err := loadImage(image)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, code := range Barcodes(img) {
+			if code == cfg.Barcode {
+				separators = append(separators, i+1)
+
+				break
+			}
+		}
+	}
+
+	return separators, len(images), nil
+}
+
+// splitParts returns the page numbers of the documents between the separator
+// pages. Empty documents (e.g. two consecutive separator sheets) are ignored.
+func splitParts(pages int, separators []int) [][]int {
+	var (
+		parts   [][]int
+		current []int
+	)
+
+	isSeparator := make(map[int]struct{}, len(separators))
+	for _, page := range separators {
+		isSeparator[page] = struct{}{}
+	}
+
+	for page := 1; page <= pages; page++ {
+		if _, ok := isSeparator[page]; ok {
+			if len(current) > 0 {
+				parts = append(parts, current)
+			}
+
+			current = nil
+
+			continue
+		}
+
+		current = append(current, page)
+	}
+
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+
+	return parts
+}
+
+// Split cuts filename at separator sheets. The documents are written to
+// files in tempdir and returned in order. If no separator is found, nil is
+// returned.
+func Split(ctx context.Context, log logrus.FieldLogger, cfg SplitConfig, tempdir, filename string) ([]string, error) {
+	separators, pages, err := SeparatorPages(ctx, cfg, tempdir, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(separators) == 0 {
+		log.Debugf("no separator sheets found")
+
+		return nil, nil
+	}
+
+	parts := splitParts(pages, separators)
+	if len(parts) == 0 {
+		log.Infof("all %d pages are separator sheets, not splitting", pages)
+
+		return nil, nil
+	}
+
+	log.Infof("found %d separator sheets %v, split into %d documents", len(separators), separators, len(parts))
+
+	dir, err := os.MkdirTemp(tempdir, "parts-")
+	if err != nil {
+		return nil, fmt.Errorf("create tempdir: %w", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	files := make([]string, 0, len(parts))
+
+	for i, part := range parts {
+		dest := filepath.Join(dir, fmt.Sprintf("%s-part%02d.pdf", base, i+1))
+
+		err := ExtractPages(ctx, tempdir, filename, dest, part)
+		if err != nil {
+			return nil, fmt.Errorf("extract part %d: %w", i+1, err)
+		}
+
+		files = append(files, dest)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,29 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitParts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pages      int
+		separators []int
+		want       [][]int
+	}{
+		{3, nil, [][]int{{1, 2, 3}}},
+		{5, []int{3}, [][]int{{1, 2}, {4, 5}}},
+		{5, []int{1, 3}, [][]int{{2}, {4, 5}}},
+		{6, []int{2, 3, 6}, [][]int{{1}, {4, 5}}},
+		{2, []int{1, 2}, nil},
+	}
+
+	for _, test := range tests {
+		got := splitParts(test.pages, test.separators)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitParts(%v, %v): want %v, got %v", test.pages, test.separators, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ThumbnailConfig configures the thumbnail rendered for each document.
+type ThumbnailConfig struct {
+	// Enabled renders a JPEG image of the first page of each document.
+	Enabled bool `json:"enabled"`
+
+	// Size is the maximum width and height of the thumbnail in pixels.
+	Size int `json:"size"`
+}
+
+// DefaultThumbnailConfig returns the default configuration, thumbnails are disabled.
+func DefaultThumbnailConfig() ThumbnailConfig {
+	return ThumbnailConfig{
+		Enabled: false,
+		Size:    800,
+	}
+}
+
+// Thumbnail renders the first page of the PDF file filename as a JPEG image
+// to target.
+func Thumbnail(ctx context.Context, cfg ThumbnailConfig, filename, target string) error {
+	err := os.MkdirAll(filepath.Dir(target), 0770)
+	if err != nil {
+		return fmt.Errorf("create thumbnail dir: %w", err)
+	}
+
+	// pdftoppm appends the extension
+	base := strings.TrimSuffix(target, filepath.Ext(target))
+
+	err = runCommand(exec.CommandContext(ctx, "pdftoppm", "-jpeg", "-singlefile",
+		"-scale-to", fmt.Sprint(cfg.Size), "-f", "1", "-l", "1", filename, base))
+	if err != nil {
+		return err
+	}
+
+	if base+".jpg" != target {
+		err = os.Rename(base+".jpg", target)
+		if err != nil {
+			return fmt.Errorf("rename thumbnail: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,686 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fd0/nepomuk/database"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// Stage is the step of the pipeline a job is waiting for.
+type Stage string
+
+const (
+	// StageProcess runs OCR and optimizations on new files in incoming/.
+	StageProcess Stage = "process"
+	// StageExtract extracts data from processed files and moves them into the archive.
+	StageExtract Stage = "extract"
+)
+
+// next returns the stage following s, the empty string is returned for the last stage.
+func (s Stage) next() Stage {
+	switch s {
+	case StageProcess:
+		return StageExtract
+	default:
+		return ""
+	}
+}
+
+// Job is a single document travelling through the pipeline.
+type Job struct {
+	ID          string    `json:"id"`
+	Stage       Stage     `json:"stage"`
+	Filename    string    `json:"filename"`
+	Source      string    `json:"source,omitempty"`
+	Original    string    `json:"original,omitempty"`
+	Thumbnail   string    `json:"thumbnail,omitempty"`
+	Part        int       `json:"part,omitempty"`
+	Parts       []string  `json:"parts,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+	NextAttempt time.Time `json:"next_attempt"`
+
+	// File holds the metadata collected for the document so far.
+	File database.File `json:"file"`
+
+	running bool
+}
+
+// ErrPermanent is wrapped by handlers for errors which will not go away by
+// retrying, the job fails immediately.
+var ErrPermanent = errors.New("permanent error")
+
+// Handler processes the file of a job for a stage. It returns the filename of
+// the result, which is passed on to the next stage. If the returned filename
+// is empty, the job is finished. Changes to job.File are kept for the next
+// stage.
+type Handler func(ctx context.Context, job *Job) (string, error)
+
+// Workers configures how the jobs for a stage are run.
+type Workers struct {
+	// Count is the number of jobs processed concurrently.
+	Count int
+
+	// Timeout limits the time a single job may take, zero means no limit.
+	Timeout time.Duration
+}
+
+// Queue is a durable job queue, the state is saved to Filename after every
+// change. Jobs which failed are retried with exponential backoff, after
+// MaxAttempts the file is moved to FailedDir along with an error report.
+type Queue struct {
+	Filename  string
+	FailedDir string
+
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// GroupFunc returns the group for a new file. Jobs within the same
+	// (non-empty) group are processed one after another in the order they
+	// were added, e.g. for the two halves of a duplex scan.
+	GroupFunc func(filename string) string
+
+	// OnFailed is called after a job failed permanently and the file was
+	// moved to FailedDir.
+	OnFailed func(job Job, err error)
+
+	log logrus.FieldLogger
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	workers map[Stage]int
+	changed chan struct{}
+}
+
+const (
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = 30 * time.Second
+	defaultMaxBackoff     = time.Hour
+)
+
+// New returns a new empty queue which saves its state to filename.
+func New(filename, failedDir string) *Queue {
+	return &Queue{
+		Filename:       filename,
+		FailedDir:      failedDir,
+		MaxAttempts:    defaultMaxAttempts,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+		log:            logrus.StandardLogger(),
+		jobs:           make(map[string]*Job),
+		workers:        make(map[Stage]int),
+		changed:        make(chan struct{}),
+	}
+}
+
+// SetLogger sets the logger the queue will use.
+func (q *Queue) SetLogger(logger logrus.FieldLogger) {
+	q.log = logger.WithField("component", "queue")
+}
+
+// Load restores the queue state from Filename. If the file does not exist, the queue is empty.
+func (q *Queue) Load() error {
+	buf, err := os.ReadFile(q.Filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("load queue: %w", err)
+	}
+
+	var jobs []*Job
+
+	err = json.Unmarshal(buf, &jobs)
+	if err != nil {
+		return fmt.Errorf("decode queue %v failed: %w", q.Filename, err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.jobs = make(map[string]*Job, len(jobs))
+	for _, job := range jobs {
+		// drop jobs for files which vanished while we were not running
+		_, err := os.Lstat(job.Filename)
+		if errors.Is(err, os.ErrNotExist) {
+			q.log.WithField("filename", job.Filename).Infof("drop job %v, file does not exist any more", job.ID)
+
+			continue
+		}
+
+		q.jobs[job.ID] = job
+	}
+
+	return nil
+}
+
+// save writes the state to Filename, q.mu must be held by the caller.
+func (q *Queue) save() error {
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Created.Before(jobs[j].Created)
+	})
+
+	buf, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serialize queue to JSON failed: %w", err)
+	}
+
+	// write to a temporary file first so the state is never half-written
+	tempfile := q.Filename + ".tmp"
+
+	err = os.WriteFile(tempfile, buf, 0600)
+	if err != nil {
+		return fmt.Errorf("save queue %v failed: %w", q.Filename, err)
+	}
+
+	err = os.Rename(tempfile, q.Filename)
+	if err != nil {
+		return fmt.Errorf("save queue %v failed: %w", q.Filename, err)
+	}
+
+	return nil
+}
+
+// notify wakes up all workers waiting for changes, q.mu must be held by the caller.
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func newID() string {
+	buf := make([]byte, 8)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic(fmt.Sprintf("unable to read random bytes: %v", err))
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+// Add inserts a new job into the queue, the fields Filename, Stage and
+// optionally Source must be set. If a job for the file already exists,
+// nothing is changed.
+func (q *Queue) Add(job Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.add(job)
+}
+
+// AddFile calls place to move the file for job to job.Filename and adds the
+// job while holding the lock, so that concurrent calls to Add for the same
+// file (e.g. from a watcher) are ignored.
+func (q *Queue) AddFile(job Job, place func() error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := place()
+	if err != nil {
+		return err
+	}
+
+	q.add(job)
+
+	return nil
+}
+
+// AddPart works like AddFile for a document split from the file of parent.
+// The filename of the part is recorded in parent.Parts, so that a retry of
+// parent does not add the part again.
+func (q *Queue) AddPart(parent *Job, part Job, place func() error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := place()
+	if err != nil {
+		return err
+	}
+
+	parent.Parts = append(parent.Parts, part.Filename)
+
+	if stored, ok := q.jobs[parent.ID]; ok {
+		stored.Parts = append(stored.Parts, part.Filename)
+	}
+
+	// saves the queue including the parts of parent
+	q.add(part)
+
+	return nil
+}
+
+// add inserts job, q.mu must be held by the caller.
+func (q *Queue) add(job Job) {
+	for _, other := range q.jobs {
+		if other.Filename == job.Filename {
+			return
+		}
+	}
+
+	now := time.Now()
+	job.ID = newID()
+	job.Created = now
+	job.Updated = now
+	job.NextAttempt = now
+
+	if q.GroupFunc != nil {
+		job.Group = q.GroupFunc(job.Filename)
+	}
+
+	q.jobs[job.ID] = &job
+	q.log.WithField("filename", job.Filename).WithField("job", job.ID).Debugf("add job for stage %v", job.Stage)
+
+	err := q.save()
+	if err != nil {
+		q.log.Warn(err)
+	}
+
+	q.notify()
+}
+
+// Jobs returns a copy of all jobs in the queue.
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Created.Before(jobs[j].Created)
+	})
+
+	return jobs
+}
+
+// next returns the next job due for stage and marks it as running. If no job
+// is due, the time of the next attempt is returned (zero if there are no jobs
+// at all) together with a channel which is closed when the queue changes.
+func (q *Queue) next(stage Stage) (*Job, time.Time, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+
+	var (
+		due  *Job
+		wait time.Time
+	)
+
+	for _, job := range q.jobs {
+		if job.Stage != stage || job.running {
+			continue
+		}
+
+		if q.blocked(job) {
+			continue
+		}
+
+		if job.NextAttempt.After(now) {
+			if wait.IsZero() || job.NextAttempt.Before(wait) {
+				wait = job.NextAttempt
+			}
+
+			continue
+		}
+
+		if due == nil || job.Created.Before(due.Created) {
+			due = job
+		}
+	}
+
+	if due != nil {
+		due.running = true
+		cpy := *due
+
+		return &cpy, time.Time{}, nil
+	}
+
+	return nil, wait, q.changed
+}
+
+// blocked returns true if job must wait for another job of the same group
+// which was added earlier, q.mu must be held by the caller.
+func (q *Queue) blocked(job *Job) bool {
+	if job.Group == "" {
+		return false
+	}
+
+	for _, other := range q.jobs {
+		if other == job || other.Group != job.Group || other.Stage != job.Stage {
+			continue
+		}
+
+		if other.running || other.Created.Before(job.Created) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StageStats describes the state of the jobs in a stage.
+type StageStats struct {
+	// Pending is the number of jobs waiting to be run.
+	Pending int `json:"pending"`
+
+	// Retrying is the number of pending jobs which failed before.
+	Retrying int `json:"retrying"`
+
+	// Running is the number of jobs currently running.
+	Running int `json:"running"`
+
+	// Workers is the number of workers for the stage.
+	Workers int `json:"workers"`
+}
+
+// Utilization returns the fraction of busy workers.
+func (s StageStats) Utilization() float64 {
+	if s.Workers == 0 {
+		return 0
+	}
+
+	return float64(s.Running) / float64(s.Workers)
+}
+
+// Stats returns the queue depth and worker utilization for all stages.
+func (q *Queue) Stats() map[Stage]StageStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := map[Stage]StageStats{
+		StageProcess: {Workers: q.workers[StageProcess]},
+		StageExtract: {Workers: q.workers[StageExtract]},
+	}
+
+	for _, job := range q.jobs {
+		st := stats[job.Stage]
+
+		switch {
+		case job.running:
+			st.Running++
+		case job.Attempts > 0:
+			st.Pending++
+			st.Retrying++
+		default:
+			st.Pending++
+		}
+
+		stats[job.Stage] = st
+	}
+
+	return stats
+}
+
+// backoff returns the time to wait before the next attempt.
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= q.MaxBackoff {
+			return q.MaxBackoff
+		}
+	}
+
+	return d
+}
+
+// finish records the result of running a job.
+func (q *Queue) finish(job *Job, result string, err error) {
+	var failed *Job
+
+	// run the callback after releasing the lock
+	defer func() {
+		if failed != nil && q.OnFailed != nil {
+			q.OnFailed(*failed, err)
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	log := q.log.WithField("filename", job.Filename).WithField("job", job.ID)
+
+	stored, ok := q.jobs[job.ID]
+	if !ok {
+		return
+	}
+
+	stored.running = false
+	stored.Updated = time.Now()
+
+	switch {
+	case err == nil && (result == "" || job.Stage.next() == ""):
+		log.Debugf("job done")
+		delete(q.jobs, job.ID)
+
+	case err == nil:
+		log.Debugf("stage %v done, result %v", job.Stage, result)
+
+		stored.Filename = result
+		stored.Original = job.Original
+		stored.Thumbnail = job.Thumbnail
+		stored.File = job.File
+		stored.Stage = job.Stage.next()
+		stored.Attempts = 0
+		stored.LastError = ""
+		stored.NextAttempt = stored.Updated
+
+	default:
+		stored.Attempts++
+		stored.LastError = err.Error()
+
+		if stored.Attempts >= q.MaxAttempts || errors.Is(err, ErrPermanent) {
+			log.Warnf("giving up after %d attempts: %v", stored.Attempts, err)
+
+			ferr := q.fail(stored)
+			if ferr != nil {
+				log.Warnf("moving file to failed dir: %v", ferr)
+			}
+
+			failedJob := *stored
+			failed = &failedJob
+
+			delete(q.jobs, job.ID)
+
+			break
+		}
+
+		stored.NextAttempt = stored.Updated.Add(q.backoff(stored.Attempts))
+		log.Warnf("attempt %d failed, retry at %v: %v",
+			stored.Attempts, stored.NextAttempt.Format(time.TimeOnly), err)
+	}
+
+	serr := q.save()
+	if serr != nil {
+		log.Warn(serr)
+	}
+
+	q.notify()
+}
+
+// failedName returns the name of filename for job in FailedDir. It contains
+// the job ID, so that failures of files with the same name do not overwrite
+// each other.
+func (q *Queue) failedName(job Job, filename string) string {
+	name := filepath.Base(filename)
+	if !strings.HasPrefix(name, job.ID) {
+		name = job.ID + "-" + name
+	}
+
+	return filepath.Join(q.FailedDir, name)
+}
+
+// FailedFilename returns the location of the file for job after it failed.
+func (q *Queue) FailedFilename(job Job) string {
+	return q.failedName(job, job.Filename)
+}
+
+// fail moves the file for job to FailedDir and writes an error report next to it.
+func (q *Queue) fail(job *Job) error {
+	err := os.MkdirAll(q.FailedDir, 0770)
+	if err != nil {
+		return fmt.Errorf("create failed dir: %w", err)
+	}
+
+	dest := q.FailedFilename(*job)
+
+	err = os.Rename(job.Filename, dest)
+	if err != nil {
+		return fmt.Errorf("move %v -> %v failed: %w", job.Filename, dest, err)
+	}
+
+	if job.Original != "" {
+		err = os.Rename(job.Original, q.failedName(*job, job.Original))
+		if err != nil {
+			return fmt.Errorf("move original %v failed: %w", job.Original, err)
+		}
+	}
+
+	// the thumbnail is not useful without the file
+	if job.Thumbnail != "" {
+		_ = os.Remove(job.Thumbnail)
+	}
+
+	var report strings.Builder
+
+	fmt.Fprintf(&report, "file:      %v\n", job.Filename)
+	fmt.Fprintf(&report, "job:       %v\n", job.ID)
+	fmt.Fprintf(&report, "original:  %v\n", job.Original)
+	fmt.Fprintf(&report, "stage:     %v\n", job.Stage)
+	fmt.Fprintf(&report, "attempts:  %v\n", job.Attempts)
+	fmt.Fprintf(&report, "created:   %v\n", job.Created.Format(time.RFC3339))
+	fmt.Fprintf(&report, "failed:    %v\n", job.Updated.Format(time.RFC3339))
+	fmt.Fprintf(&report, "error:     %v\n", job.LastError)
+
+	err = os.WriteFile(dest+".error.txt", []byte(report.String()), 0600)
+	if err != nil {
+		return fmt.Errorf("write error report: %w", err)
+	}
+
+	return nil
+}
+
+// Run processes all jobs for stage with handler until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context, stage Stage, workers Workers, handler Handler) error {
+	count := workers.Count
+	if count < 1 {
+		count = 1
+	}
+
+	q.mu.Lock()
+	q.workers[stage] += count
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		q.workers[stage] -= count
+		q.mu.Unlock()
+	}()
+
+	q.log.Debugf("start %d workers for stage %v", count, stage)
+
+	var wg errgroup.Group
+
+	for i := 0; i < count; i++ {
+		wg.Go(func() error {
+			return q.work(ctx, stage, workers.Timeout, handler)
+		})
+	}
+
+	return wg.Wait()
+}
+
+// work runs jobs for stage until ctx is cancelled.
+func (q *Queue) work(ctx context.Context, stage Stage, timeout time.Duration, handler Handler) error {
+	for {
+		job, wait, changed := q.next(stage)
+		if job != nil {
+			result, err := q.runJob(ctx, job, timeout, handler)
+
+			// do not count jobs interrupted by shutdown as failed attempts
+			if ctx.Err() != nil {
+				q.release(job)
+
+				return nil
+			}
+
+			q.finish(job, result, err)
+
+			continue
+		}
+
+		var (
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+
+		if !wait.IsZero() {
+			timer = time.NewTimer(time.Until(wait))
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-timeout:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// runJob calls handler for job, limiting the runtime to timeout if it is not zero.
+func (q *Queue) runJob(ctx context.Context, job *Job, timeout time.Duration, handler Handler) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := handler(ctx, job)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timeout after %v: %w", timeout, err)
+	}
+
+	return result, err
+}
+
+// release marks job as not running without recording a result.
+func (q *Queue) release(job *Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if stored, ok := q.jobs[job.ID]; ok {
+		stored.running = false
+	}
+}
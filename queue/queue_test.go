@@ -0,0 +1,278 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	q := New("", "")
+	q.InitialBackoff = time.Second
+	q.MaxBackoff = 10 * time.Second
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		got := q.backoff(test.attempts)
+		if got != test.want {
+			t.Errorf("backoff(%v): want %v, got %v", test.attempts, test.want, got)
+		}
+	}
+}
+
+func TestQueueStages(t *testing.T) {
+	t.Parallel()
+
+	tempdir := t.TempDir()
+	filename := filepath.Join(tempdir, "foo.pdf")
+
+	err := os.WriteFile(filename, []byte("foo"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := New(filepath.Join(tempdir, "queue.json"), filepath.Join(tempdir, "failed"))
+	q.Add(Job{Filename: filename, Stage: StageProcess})
+	q.Add(Job{Filename: filename, Stage: StageProcess})
+
+	if len(q.Jobs()) != 1 {
+		t.Fatalf("want one job, got %v", q.Jobs())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan string)
+
+	go func() {
+		_ = q.Run(ctx, StageProcess, Workers{Count: 2}, func(_ context.Context, job *Job) (string, error) {
+			return job.Filename + ".processed", nil
+		})
+	}()
+
+	go func() {
+		_ = q.Run(ctx, StageExtract, Workers{Count: 2}, func(_ context.Context, job *Job) (string, error) {
+			done <- job.Filename
+
+			return "", nil
+		})
+	}()
+
+	select {
+	case name := <-done:
+		if name != filename+".processed" {
+			t.Errorf("wrong filename passed to extract stage: %v", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for job")
+	}
+
+	// reload the queue from disk, the job must be gone
+	q2 := New(q.Filename, q.FailedDir)
+
+	err = q2.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(q2.Jobs()) != 0 {
+		t.Errorf("queue is not empty: %v", q2.Jobs())
+	}
+}
+
+func TestQueueFailed(t *testing.T) {
+	t.Parallel()
+
+	tempdir := t.TempDir()
+	filename := filepath.Join(tempdir, "foo.pdf")
+
+	err := os.WriteFile(filename, []byte("foo"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := New(filepath.Join(tempdir, "queue.json"), filepath.Join(tempdir, "failed"))
+	q.MaxAttempts = 3
+	q.InitialBackoff = time.Millisecond
+
+	failed := make(chan Job, 1)
+	q.OnFailed = func(job Job, _ error) {
+		failed <- job
+	}
+
+	q.Add(Job{Filename: filename, Stage: StageProcess})
+
+	id := q.Jobs()[0].ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	attempts := make(chan struct{}, 10)
+
+	go func() {
+		_ = q.Run(ctx, StageProcess, Workers{Count: 2}, func(_ context.Context, _ *Job) (string, error) {
+			attempts <- struct{}{}
+
+			return "", errors.New("test error")
+		})
+	}()
+
+	for i := 0; i < q.MaxAttempts; i++ {
+		select {
+		case <-attempts:
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for attempt %d", i+1)
+		}
+	}
+
+	// wait until the job has been removed from the queue
+	for start := time.Now(); len(q.Jobs()) > 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("job was not removed from the queue: %v", q.Jobs())
+		}
+	}
+
+	select {
+	case job := <-failed:
+		if job.Filename != filename || job.LastError != "test error" {
+			t.Errorf("wrong job passed to OnFailed: %+v", job)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("OnFailed was not called")
+	}
+
+	// the job ID is part of the name, so failures of files with the same name
+	// do not overwrite each other
+	for _, name := range []string{id + "-foo.pdf", id + "-foo.pdf.error.txt"} {
+		_, err := os.Stat(filepath.Join(q.FailedDir, name))
+		if err != nil {
+			t.Errorf("file %v not found in failed dir: %v", name, err)
+		}
+	}
+}
+
+func TestQueueGroup(t *testing.T) {
+	t.Parallel()
+
+	tempdir := t.TempDir()
+
+	q := New(filepath.Join(tempdir, "queue.json"), filepath.Join(tempdir, "failed"))
+	q.GroupFunc = func(string) string {
+		return "group"
+	}
+
+	var files []string
+
+	for _, name := range []string{"a.pdf", "b.pdf", "c.pdf"} {
+		filename := filepath.Join(tempdir, name)
+
+		err := os.WriteFile(filename, []byte(name), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q.Add(Job{Filename: filename, Stage: StageProcess})
+		files = append(files, filename)
+
+		// make sure the creation timestamps differ
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	order := make(chan string, len(files))
+	running := make(chan struct{}, 1)
+
+	go func() {
+		_ = q.Run(ctx, StageProcess, Workers{Count: len(files)}, func(_ context.Context, job *Job) (string, error) {
+			select {
+			case running <- struct{}{}:
+			default:
+				t.Errorf("more than one job of the group is running")
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			order <- job.Filename
+			<-running
+
+			return "", nil
+		})
+	}()
+
+	for _, want := range files {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("wrong order, want %v, got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for job")
+		}
+	}
+}
+
+func TestQueueAddPart(t *testing.T) {
+	t.Parallel()
+
+	tempdir := t.TempDir()
+	filename := filepath.Join(tempdir, "foo.pdf")
+
+	q := New(filepath.Join(tempdir, "queue.json"), filepath.Join(tempdir, "failed"))
+
+	err := q.AddFile(Job{Filename: filename, Stage: StageProcess}, func() error {
+		return os.WriteFile(filename, []byte("foo"), 0600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := q.Jobs()[0]
+	part := filepath.Join(tempdir, "foo-part01.pdf")
+
+	err = q.AddPart(&parent, Job{Filename: part, Stage: StageProcess, Part: 1}, func() error {
+		return os.WriteFile(part, []byte("part"), 0600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parent.Parts) != 1 || parent.Parts[0] != part {
+		t.Errorf("part not recorded in parent: %v", parent.Parts)
+	}
+
+	// the parts must be recorded in the queue on disk
+	q2 := New(q.Filename, q.FailedDir)
+
+	err = q2.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := q2.Jobs()
+	if len(jobs) != 2 {
+		t.Fatalf("want two jobs, got %v", jobs)
+	}
+
+	for _, job := range jobs {
+		if job.ID == parent.ID && (len(job.Parts) != 1 || job.Parts[0] != part) {
+			t.Errorf("part not recorded in stored job: %v", job.Parts)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fd0/nepomuk/config"
+	"github.com/fd0/nepomuk/database"
+	"github.com/fd0/nepomuk/ingest"
+	"github.com/fd0/nepomuk/process"
+	"github.com/fd0/nepomuk/queue"
+	"github.com/sirupsen/logrus"
+)
+
+// we need to use the dot to specify millisecond precision, it will be replaced later
+const uploadFilenameTimeFormat = "20060102-150405.000000"
+
+// uploadStagingDir is the directory below the archive where files uploaded
+// via WebDAV are stored until the upload is complete.
+const uploadStagingDir = ".nepomuk/upload-staging"
+
+// temporaryUploadExtensions are used by clients which upload to a temporary
+// file and rename it afterwards.
+var temporaryUploadExtensions = []string{".tmp", ".part", ".partial"}
+
+// uploadStager moves complete uploads from the staging directory into the
+// incoming directory and adds them to the queue.
+type uploadStager struct {
+	Dir         string
+	IncomingDir string
+	Queue       *queue.Queue
+
+	// FailedDir receives uploads which are too small to be processed.
+	FailedDir string
+
+	// MinSize is the minimal size of an upload, smaller files are moved to
+	// FailedDir.
+	MinSize int64
+
+	// Uploaders maps the root directory of a user to the user.
+	Uploaders map[string]config.WebDAVUser
+
+	log *logrus.Entry
+
+	// mu serializes moving files, so that each gets a unique name
+	mu sync.Mutex
+}
+
+// uploadName returns a new unique name for an upload with extension ext.
+func uploadName(ext string) string {
+	name := time.Now().Format(uploadFilenameTimeFormat)
+	// replace the dot used for specifying millisecond precision
+	name = strings.ReplaceAll(name, ".", "_")
+
+	return name + ext
+}
+
+// Accept is called when the upload of the file name (a slash-separated path
+// below Dir) is complete. Files which are too small are moved to FailedDir,
+// other files which are ignored or incomplete are left in the staging
+// directory. An error is only returned if the file could not be moved.
+func (s *uploadStager) Accept(name string) error {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	filename := filepath.Join(s.Dir, filepath.FromSlash(name))
+
+	log := s.log.WithField("filename", name)
+
+	// ignore files with . or _ as first characters
+	if base := path.Base(name); base[0] == '.' || base[0] == '_' {
+		log.Tracef("ignore file with special filename")
+
+		return nil
+	}
+
+	// temporary files are accepted when they are renamed
+	if slices.Contains(temporaryUploadExtensions, strings.ToLower(path.Ext(name))) {
+		log.Tracef("ignore temporary file")
+
+		return nil
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("stat upload: %w", err)
+	}
+
+	// very small files are not complete documents, e.g. empty files
+	// created before the upload
+	if fi.Size() < s.MinSize {
+		return s.reject(filename, name, fi.Size())
+	}
+
+	contentType, err := process.ContentType(filename)
+	if err != nil {
+		return err
+	}
+
+	if contentType == process.ContentTypePDF || strings.EqualFold(path.Ext(name), ".pdf") {
+		err = ingest.CheckPDF(filename)
+		if errors.Is(err, ingest.ErrIncompletePDF) {
+			log.Warnf("ignore upload: %v", err)
+
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newName := uploadName(path.Ext(name))
+
+	job := queue.Job{
+		Filename: filepath.Join(s.IncomingDir, newName),
+		Stage:    queue.StageProcess,
+		Source:   ingest.SourceWebDAV,
+	}
+
+	root, _, _ := strings.Cut(name, "/")
+	if user, ok := s.Uploaders[root]; ok {
+		job.File.UploadedBy = user.Name
+		job.File.Tags = database.NormalizeTags(user.Tags)
+	}
+
+	// the staging dir is on the same file system as the incoming dir, so the
+	// file appears there atomically
+	err = s.Queue.AddFile(job, func() error {
+		return os.Rename(filename, job.Filename)
+	})
+	if err != nil {
+		return fmt.Errorf("move to incoming dir: %w", err)
+	}
+
+	log.Debugf("new upload, %d bytes, moved to %v", fi.Size(), newName)
+
+	return nil
+}
+
+// AcceptExisting calls Accept for all files in the staging directory, e.g.
+// uploads which were completed before a restart. Files of uploads which were
+// interrupted by a crash are removed.
+func (s *uploadStager) AcceptExisting() error {
+	return filepath.WalkDir(s.Dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Dir, filename)
+		if err != nil {
+			return fmt.Errorf("relative path for %v: %w", filename, err)
+		}
+
+		if base := d.Name(); base[0] == '.' && strings.HasSuffix(base, partialUploadSuffix) {
+			s.log.Infof("remove interrupted upload %v", rel)
+
+			err = os.Remove(filename)
+			if err != nil {
+				s.log.Warnf("remove interrupted upload: %v", err)
+			}
+
+			return nil
+		}
+
+		err = s.Accept(filepath.ToSlash(rel))
+		if err != nil {
+			s.log.Warnf("staged upload %v: %v", rel, err)
+		}
+
+		return nil
+	})
+}
+
+// reject moves the upload filename (rel within the staging dir), which is
+// smaller than MinSize, to FailedDir.
+func (s *uploadStager) reject(filename, rel string, size int64) error {
+	dest := filepath.Join(s.FailedDir, uploadName("-"+path.Base(rel)))
+
+	s.log.Warnf("upload %v is too small (%d bytes), moving it to %v", rel, size, dest)
+
+	err := os.MkdirAll(s.FailedDir, 0770)
+	if err != nil {
+		return fmt.Errorf("create failed dir: %w", err)
+	}
+
+	err = os.Rename(filename, dest)
+	if err != nil {
+		return fmt.Errorf("move small upload: %w", err)
+	}
+
+	return nil
+}
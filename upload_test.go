@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fd0/nepomuk/config"
+	"github.com/fd0/nepomuk/queue"
+	"github.com/sirupsen/logrus"
+)
+
+const testPDF = "%PDF-1.4\n1 0 obj << >> endobj\ntrailer << >>\n%%EOF\n"
+
+// newTestStager returns an uploadStager with temporary directories.
+func newTestStager(t testing.TB) *uploadStager {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	stager := &uploadStager{
+		Dir:         filepath.Join(dir, "staging"),
+		IncomingDir: filepath.Join(dir, "incoming"),
+		FailedDir:   filepath.Join(dir, "failed"),
+		Queue:       queue.New(filepath.Join(dir, "queue.json"), filepath.Join(dir, "failed")),
+		Uploaders:   make(map[string]config.WebDAVUser),
+		log:         logrus.NewEntry(logrus.New()),
+	}
+
+	for _, d := range []string{stager.Dir, stager.IncomingDir} {
+		err := os.Mkdir(d, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return stager
+}
+
+func TestWebDAVUploadedBy(t *testing.T) {
+	t.Parallel()
+
+	stager := newTestStager(t)
+
+	alice := testWebDAVUser(t, "alice", "alice-secret")
+	alice.Tags = []string{"Private"}
+
+	bob := testWebDAVUser(t, "bob", "bob-secret")
+	bob.Root = "scans"
+
+	handler, err := newWebDAVHandler(context.Background(), logrus.New(), nil, []config.WebDAVUser{alice, bob}, stager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	tests := []struct {
+		user, password string
+		path           string
+		code           int
+	}{
+		{"alice", "alice-secret", "/invoice.pdf", http.StatusCreated},
+		// the path cannot leave the directory of the user
+		{"bob", "bob-secret", "/../letter.pdf", http.StatusCreated},
+		{"bob", "bob-secret", "/../alice/letter.pdf", http.StatusNotFound},
+		{"mallory", "alice-secret", "/evil.pdf", http.StatusUnauthorized},
+		{"alice", "bob-secret", "/evil.pdf", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodPut, srv.URL+test.path, strings.NewReader(testPDF))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.SetBasicAuth(test.user, test.password)
+
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = res.Body.Close()
+
+		if res.StatusCode != test.code {
+			t.Errorf("PUT %v as %v: want status %v, got %v", test.path, test.user, test.code, res.StatusCode)
+		}
+	}
+
+	jobs := stager.Queue.Jobs()
+	if len(jobs) != 2 {
+		t.Fatalf("want two jobs, got %v", jobs)
+	}
+
+	for i, want := range []string{"alice", "bob"} {
+		job := jobs[i]
+
+		if job.File.UploadedBy != want {
+			t.Errorf("job %v: want uploader %v, got %q", i, want, job.File.UploadedBy)
+		}
+
+		if filepath.Dir(job.Filename) != stager.IncomingDir {
+			t.Errorf("job %v: file %v is not in the incoming dir", i, job.Filename)
+		}
+
+		buf, err := os.ReadFile(job.Filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(buf) != testPDF {
+			t.Errorf("job %v: wrong content %q", i, buf)
+		}
+	}
+
+	if !slices.Equal(jobs[0].File.Tags, []string{"private"}) {
+		t.Errorf("tags of alice not added: %v", jobs[0].File.Tags)
+	}
+
+	if len(jobs[1].File.Tags) != 0 {
+		t.Errorf("unexpected tags for bob: %v", jobs[1].File.Tags)
+	}
+}
+
+func TestAcceptExisting(t *testing.T) {
+	t.Parallel()
+
+	stager := newTestStager(t)
+
+	err := os.Mkdir(filepath.Join(stager.Dir, "alice"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		// completed before a restart
+		"alice/complete.pdf": testPDF,
+		// interrupted by a crash, the data looks like a complete image
+		"alice/.scan.jpg" + partialUploadSuffix: "\xff\xd8\xff\xe0 truncated",
+	}
+
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(stager.Dir, filepath.FromSlash(name)), []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = stager.AcceptExisting()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := stager.Queue.Jobs()
+	if len(jobs) != 1 || filepath.Ext(jobs[0].Filename) != ".pdf" {
+		t.Fatalf("want one job for the complete upload, got %v", jobs)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(stager.Dir, "alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("staging dir is not empty: %v", entries)
+	}
+}
+
+func TestAcceptSmallFile(t *testing.T) {
+	t.Parallel()
+
+	stager := newTestStager(t)
+	stager.MinSize = 1000
+
+	filename := filepath.Join(stager.Dir, "small.pdf")
+
+	err := os.WriteFile(filename, []byte(testPDF), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stager.Accept("small.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jobs := stager.Queue.Jobs(); len(jobs) != 0 {
+		t.Fatalf("want no jobs, got %v", jobs)
+	}
+
+	// small files are moved out of the way, so they are not checked again
+	_, err = os.Stat(filename)
+	if !os.IsNotExist(err) {
+		t.Errorf("small file is still in the staging dir: %v", err)
+	}
+
+	failed, err := filepath.Glob(filepath.Join(stager.FailedDir, "*-small.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failed) != 1 {
+		t.Errorf("want small file in the failed dir, got %v", failed)
+	}
+}
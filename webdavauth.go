@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/fd0/nepomuk/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// webDAVUser is a user with the handler for the user's upload directory.
+type webDAVUser struct {
+	config.WebDAVUser
+	handler http.Handler
+}
+
+// webDAVAuth authenticates users with HTTP basic auth and passes the request
+// on to the handler of the user.
+type webDAVAuth struct {
+	users map[string]webDAVUser
+	log   logrus.FieldLogger
+
+	// fallback is compared for unknown users, so that they cannot be told
+	// apart from wrong passwords by the response time
+	fallback []byte
+}
+
+func newWebDAVAuth(log logrus.FieldLogger, users []webDAVUser) *webDAVAuth {
+	auth := &webDAVAuth{
+		users: make(map[string]webDAVUser, len(users)),
+		log:   log,
+	}
+
+	for _, user := range users {
+		auth.users[user.Name] = user
+
+		if auth.fallback == nil {
+			auth.fallback = []byte(user.PasswordHash)
+		}
+	}
+
+	return auth
+}
+
+func (a *webDAVAuth) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	name, password, ok := req.BasicAuth()
+
+	user, found := a.users[name]
+
+	hash := a.fallback
+	if found {
+		hash = []byte(user.PasswordHash)
+	}
+
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if !ok || !found || err != nil {
+		if ok {
+			a.log.Warnf("authentication failed for user %q from %v", name, req.RemoteAddr)
+		}
+
+		res.Header().Set("WWW-Authenticate", `Basic realm="nepomuk", charset="UTF-8"`)
+		http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+		return
+	}
+
+	user.handler.ServeHTTP(res, req)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fd0/nepomuk/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testWebDAVUser returns a user with a bcrypt hash of password.
+func testWebDAVUser(t testing.TB, name, password string) config.WebDAVUser {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config.WebDAVUser{Name: name, PasswordHash: string(hash)}
+}
+
+func TestWebDAVAuth(t *testing.T) {
+	t.Parallel()
+
+	var users []webDAVUser
+
+	for _, name := range []string{"alice", "bob"} {
+		users = append(users, webDAVUser{
+			WebDAVUser: testWebDAVUser(t, name, name+"-secret"),
+			handler: http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(res, name)
+			}),
+		})
+	}
+
+	srv := httptest.NewServer(newWebDAVAuth(logrus.New(), users))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		noAuth   bool
+		code     int
+		body     string
+	}{
+		{name: "alice", user: "alice", password: "alice-secret", code: http.StatusOK, body: "alice"},
+		{name: "bob", user: "bob", password: "bob-secret", code: http.StatusOK, body: "bob"},
+		{name: "no-auth", noAuth: true, code: http.StatusUnauthorized},
+		{name: "unknown-user", user: "mallory", password: "alice-secret", code: http.StatusUnauthorized},
+		{name: "wrong-password", user: "alice", password: "bob-secret", code: http.StatusUnauthorized},
+		{name: "empty-password", user: "alice", code: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !test.noAuth {
+				req.SetBasicAuth(test.user, test.password)
+			}
+
+			res, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != test.code {
+				t.Fatalf("want status %v, got %v", test.code, res.StatusCode)
+			}
+
+			if test.code == http.StatusUnauthorized {
+				if res.Header.Get("WWW-Authenticate") == "" {
+					t.Errorf("header WWW-Authenticate is missing")
+				}
+
+				return
+			}
+
+			if string(body) != test.body {
+				t.Errorf("request passed to the wrong handler, want %q, got %q", test.body, body)
+			}
+		})
+	}
+}
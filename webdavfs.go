@@ -1,90 +1,254 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/fs"
+	"net/http"
 	"os"
+	"path"
+	"strings"
+	"time"
 
 	"golang.org/x/net/webdav"
 )
 
-// webDAVFS adapts a webdav.FileSystem to fs.FS.
-type webDAVFS struct {
-	fs webdav.FileSystem
+// rootFS restricts a webdav.FileSystem to the directory root.
+type rootFS struct {
+	fs   webdav.FileSystem
+	root string
 }
 
-// statically ensure that webDAVFS implements fs.FS.
-var _ fs.StatFS = &webDAVFS{}
+// statically ensure that rootFS implements webdav.FileSystem.
+var _ webdav.FileSystem = rootFS{}
 
-// newWebDAVFS adapts fs to a fs.FS.
-func newWebDAVFS(fs webdav.FileSystem) *webDAVFS {
-	return &webDAVFS{fs}
+// resolve returns the name in the underlying file system, it cannot leave root.
+func (r rootFS) resolve(name string) string {
+	return path.Join(r.root, path.Clean("/"+name))
 }
 
-// Open opens the named file.
-// When Open returns an error, it should be of type *PathError
-// with the Op field set to "open", the Path field set to name,
-// and the Err field describing the problem.
-//
-// Open should reject attempts to open names that do not satisfy
-// ValidPath(name), returning a *PathError with Err set to
-// ErrInvalid or ErrNotExist.
-func (fs *webDAVFS) Open(name string) (fs.File, error) {
-	f, err := fs.fs.OpenFile(context.Background(), name, os.O_RDONLY, 0644)
+func (r rootFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return r.fs.Mkdir(ctx, r.resolve(name), perm)
+}
+
+func (r rootFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	return r.fs.OpenFile(ctx, r.resolve(name), flag, perm)
+}
+
+func (r rootFS) RemoveAll(ctx context.Context, name string) error {
+	// never remove the root directory itself
+	if r.resolve(name) == path.Clean(r.root) {
+		return os.ErrPermission
+	}
+
+	return r.fs.RemoveAll(ctx, r.resolve(name))
+}
+
+func (r rootFS) Rename(ctx context.Context, oldName, newName string) error {
+	return r.fs.Rename(ctx, r.resolve(oldName), r.resolve(newName))
+}
+
+func (r rootFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return r.fs.Stat(ctx, r.resolve(name))
+}
+
+// rootLS restricts a webdav.LockSystem to the directory root, so that locks
+// match the names in a rootFS.
+type rootLS struct {
+	ls   webdav.LockSystem
+	root string
+}
+
+// statically ensure that rootLS implements webdav.LockSystem.
+var _ webdav.LockSystem = rootLS{}
+
+func (r rootLS) resolve(name string) string {
+	// an empty name is not checked by the lock system
+	if name == "" {
+		return ""
+	}
+
+	return rootFS{root: r.root}.resolve(name)
+}
+
+func (r rootLS) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (func(), error) {
+	return r.ls.Confirm(now, r.resolve(name0), r.resolve(name1), conditions...)
+}
+
+func (r rootLS) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	details.Root = r.resolve(details.Root)
+
+	return r.ls.Create(now, details)
+}
+
+func (r rootLS) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	details, err := r.ls.Refresh(now, token, duration)
+	if err != nil {
+		return details, err
+	}
+
+	details.Root = "/" + strings.TrimPrefix(strings.TrimPrefix(details.Root, path.Clean(r.root)), "/")
+
+	return details, nil
+}
+
+func (r rootLS) Unlock(now time.Time, token string) error {
+	return r.ls.Unlock(now, token)
+}
+
+// uploadSizeKey is the context key for the announced size of an upload.
+type uploadSizeKey struct{}
+
+// withUploadSize stores the Content-Length of PUT requests in the request
+// context, so that uploadFS can detect interrupted uploads. It is -1 if the
+// client did not announce the size, e.g. for chunked uploads.
+func withUploadSize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPut {
+			req = req.WithContext(context.WithValue(req.Context(), uploadSizeKey{}, req.ContentLength))
+		}
+
+		next.ServeHTTP(res, req)
+	})
+}
+
+// partialUploadSuffix marks the hidden file an upload is written to, it is
+// only renamed to the real name when the upload is complete.
+const partialUploadSuffix = ".uploading"
+
+// partialUploadName returns a new unique name of a file the upload of name
+// is written to, so concurrent uploads of the same name do not interfere.
+func partialUploadName(name string) string {
+	buf := make([]byte, 8)
+
+	_, err := rand.Read(buf)
 	if err != nil {
-		return nil, fmt.Errorf("open: %w", err)
+		panic(fmt.Sprintf("unable to read random bytes: %v", err))
 	}
 
-	return &webDAVFile{f}, nil
+	return path.Join(path.Dir(name), "."+path.Base(name)+"-"+hex.EncodeToString(buf)+partialUploadSuffix)
 }
 
-// Stat returns a FileInfo describing the file.
-// If there is an error, it should be of type *PathError.
-func (fs *webDAVFS) Stat(name string) (fs.FileInfo, error) {
-	return fs.fs.Stat(context.Background(), name)
+// errUnknownUploadSize is returned for uploads which cannot be checked for
+// completeness.
+var errUnknownUploadSize = errors.New("upload without Content-Length which is not a PDF file")
+
+// uploadFS wraps a webdav.FileSystem and calls OnComplete when a file
+// uploaded with PUT is closed, or when a file is renamed. Uploads are written
+// to a hidden name until they are complete, so interrupted uploads are never
+// passed on, not even after a crash.
+type uploadFS struct {
+	webdav.FileSystem
+
+	OnComplete func(name string) error
 }
 
-// RemoveAll removes path and any children it contains. It removes everything
-// it can but returns the first error it encounters. If the path does not
-// exist, RemoveAll returns nil (no error). If there is an error, it will be of
-// type *PathError.
-func (fs *webDAVFS) RemoveAll(name string) error {
-	return fs.fs.RemoveAll(context.Background(), name)
+// statically ensure that uploadFS implements webdav.FileSystem.
+var _ webdav.FileSystem = uploadFS{}
+
+func (u uploadFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	expected, upload := ctx.Value(uploadSizeKey{}).(int64)
+
+	// only PUT replaces the file, other requests such as LOCK (which may
+	// create an empty file) or PROPPATCH work on the file itself
+	if !upload || flag&(os.O_CREATE|os.O_TRUNC) != os.O_CREATE|os.O_TRUNC {
+		return u.FileSystem.OpenFile(ctx, name, flag, perm)
+	}
+
+	partial := partialUploadName(name)
+
+	f, err := u.FileSystem.OpenFile(ctx, partial, flag|os.O_EXCL, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &uploadFile{
+		File:     f,
+		expected: expected,
+		complete: func() error {
+			err := u.FileSystem.Rename(ctx, partial, name)
+			if err != nil {
+				return err
+			}
+
+			return u.OnComplete(name)
+		},
+		discard: func() error {
+			return u.FileSystem.RemoveAll(ctx, partial)
+		},
+	}, nil
 }
 
-// webDAVFile adapts webdav.File to fs.File (different name for ReadDir() vs. Readdir()).
-type webDAVFile struct {
+func (u uploadFS) Rename(ctx context.Context, oldName, newName string) error {
+	err := u.FileSystem.Rename(ctx, oldName, newName)
+	if err != nil {
+		return err
+	}
+
+	fi, err := u.FileSystem.Stat(ctx, newName)
+	if err != nil || fi.IsDir() {
+		return nil
+	}
+
+	return u.OnComplete(newName)
+}
+
+// uploadFile counts the bytes written to a file.
+type uploadFile struct {
 	webdav.File
+
+	// complete moves the file to name and passes it on, discard removes an
+	// incomplete upload
+	complete func() error
+	discard  func() error
+
+	// expected is the size announced by the client, -1 if unknown
+	expected int64
+	written  int64
+	failed   bool
+
+	// header holds the first bytes of the file
+	header []byte
 }
 
-var _ fs.ReadDirFile = &webDAVFile{}
-
-// ReadDir reads the contents of the directory and returns
-// a slice of up to n DirEntry values in directory order.
-// Subsequent calls on the same file will yield further DirEntry values.
-//
-// If n > 0, ReadDir returns at most n DirEntry structures.
-// In this case, if ReadDir returns an empty slice, it will return
-// a non-nil error explaining why.
-// At the end of a directory, the error is io.EOF.
-// (ReadDir must return io.EOF itself, not an error wrapping io.EOF.)
-//
-// If n <= 0, ReadDir returns all the DirEntry values from the directory
-// in a single slice. In this case, if ReadDir succeeds (reads all the way
-// to the end of the directory), it returns the slice and a nil error.
-// If it encounters an error before the end of the directory,
-// ReadDir returns the DirEntry list read until that point and a non-nil error.
-func (f *webDAVFile) ReadDir(n int) ([]fs.DirEntry, error) {
-	entries, err := f.File.Readdir(n)
+// pdfHeader starts each PDF file.
+var pdfHeader = []byte("%PDF-")
+
+func (f *uploadFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.written += int64(n)
+
+	if missing := len(pdfHeader) - len(f.header); missing > 0 {
+		f.header = append(f.header, p[:min(missing, n)]...)
+	}
+
+	if err != nil {
+		f.failed = true
+	}
+
+	return n, err
+}
+
+// Close closes the file, the upload is complete if all data announced by the
+// client was written successfully. Incomplete uploads are removed, the client
+// needs to upload the file again. If the client did not announce the size,
+// only PDF files are accepted, their end-of-file marker is checked later.
+func (f *uploadFile) Close() error {
+	err := f.File.Close()
 	if err != nil {
-		return nil, fmt.Errorf("ReadDir: %w", err)
+		return errors.Join(err, f.discard())
+	}
+
+	if f.failed || (f.expected >= 0 && f.written != f.expected) {
+		return f.discard()
 	}
 
-	list := make([]fs.DirEntry, 0, len(entries))
-	for _, entry := range entries {
-		list = append(list, fs.FileInfoToDirEntry(entry))
+	if f.expected < 0 && !bytes.Equal(f.header, pdfHeader) {
+		return errors.Join(errUnknownUploadSize, f.discard())
 	}
 
-	return list, nil
+	return f.complete()
 }
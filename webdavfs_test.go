@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+func TestRootFSResolve(t *testing.T) {
+	t.Parallel()
+
+	fs := rootFS{root: "/alice"}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", "/alice"},
+		{"/", "/alice"},
+		{"foo.pdf", "/alice/foo.pdf"},
+		{"/sub/foo.pdf", "/alice/sub/foo.pdf"},
+		{"..", "/alice"},
+		{"/../bob/foo.pdf", "/alice/bob/foo.pdf"},
+		{"sub/../../../foo.pdf", "/alice/foo.pdf"},
+		{"/etc/passwd", "/alice/etc/passwd"},
+	}
+
+	for _, test := range tests {
+		got := fs.resolve(test.name)
+		if got != test.want {
+			t.Errorf("resolve(%q): want %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestRootFSConfinement(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for _, name := range []string{"alice", "bob"} {
+		err := os.Mkdir(filepath.Join(dir, name), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := rootFS{fs: webdav.Dir(dir), root: "/alice"}
+	handler := &webdav.Handler{
+		FileSystem: fs,
+		LockSystem: rootLS{ls: webdav.NewMemLS(), root: "/alice"},
+	}
+
+	for _, target := range []string{"/../foo.pdf", "/../bob/../bar.pdf", "/%2e%2e/baz.pdf"} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodPut, target, strings.NewReader("data")))
+
+		if res.Code != http.StatusCreated {
+			t.Errorf("PUT %v: want status %v, got %v", target, http.StatusCreated, res.Code)
+		}
+	}
+
+	// all files must end up in the directory of alice
+	for _, name := range []string{"foo.pdf", "bar.pdf", "baz.pdf"} {
+		_, err := os.Stat(filepath.Join(dir, "alice", name))
+		if err != nil {
+			t.Errorf("file %v not found in root: %v", name, err)
+		}
+
+		_, err = os.Stat(filepath.Join(dir, name))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("file %v was created outside of the root", name)
+		}
+	}
+
+	err := fs.RemoveAll(context.Background(), "/")
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("removing the root returned %v", err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "alice"))
+	if err != nil {
+		t.Errorf("root was removed: %v", err)
+	}
+}
+
+func TestRootLS(t *testing.T) {
+	t.Parallel()
+
+	ls := webdav.NewMemLS()
+	alice := rootLS{ls: ls, root: "/alice"}
+	now := time.Now()
+
+	token, err := alice.Create(now, webdav.LockDetails{Root: "/foo.pdf", Duration: time.Minute, ZeroDepth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lock is stored with the name in the underlying file system
+	_, err = ls.Create(now, webdav.LockDetails{Root: "/alice/foo.pdf", Duration: time.Minute, ZeroDepth: true})
+	if !errors.Is(err, webdav.ErrLocked) {
+		t.Errorf("file is not locked in the underlying lock system: %v", err)
+	}
+
+	_, err = ls.Confirm(now, "/foo.pdf", "", webdav.Condition{Token: token})
+	if !errors.Is(err, webdav.ErrConfirmationFailed) {
+		t.Errorf("lock applies to a file outside of the root: %v", err)
+	}
+
+	release, err := alice.Confirm(now, "/foo.pdf", "", webdav.Condition{Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release()
+
+	details, err := alice.Refresh(now, token, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if details.Root != "/foo.pdf" {
+		t.Errorf("refresh returned root %q", details.Root)
+	}
+}
+
+func TestUploadFileShortWrite(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	var completed []string
+
+	fs := uploadFS{
+		FileSystem: webdav.Dir(dir),
+		OnComplete: func(name string) error {
+			completed = append(completed, name)
+
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		expected int64
+		data     string
+		complete bool
+	}{
+		{"complete.pdf", 4, "data", true},
+		{"short.pdf", 100, "data", false},
+		{"long.pdf", 2, "data", false},
+		// without Content-Length only PDF files are accepted, the
+		// end-of-file marker is checked when the upload is accepted
+		{"unknown-size.pdf", -1, "%PDF-1.4 data", true},
+		{"unknown-size.jpg", -1, "\xff\xd8\xff\xe0 data", false},
+		{"unknown-size-short.pdf", -1, "%PD", false},
+	}
+
+	for _, test := range tests {
+		ctx := context.WithValue(context.Background(), uploadSizeKey{}, test.expected)
+
+		f, err := fs.OpenFile(ctx, "/"+test.name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.Write([]byte(test.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		completed = nil
+
+		err = f.Close()
+		if test.complete && err != nil {
+			t.Fatal(err)
+		}
+
+		if test.complete != (len(completed) == 1) {
+			t.Errorf("%v: want complete %v, got %v", test.name, test.complete, completed)
+		}
+
+		// incomplete uploads must not be left behind
+		_, err = os.Stat(filepath.Join(dir, test.name))
+		if test.complete != (err == nil) {
+			t.Errorf("%v: want file %v, got %v", test.name, test.complete, err)
+		}
+
+		partial, err := filepath.Glob(filepath.Join(dir, "."+test.name+"-*"+partialUploadSuffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(partial) != 0 {
+			t.Errorf("%v: partial upload was not removed: %v", test.name, partial)
+		}
+	}
+}
+
+func TestUploadFSConcurrentUploads(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	fs := uploadFS{
+		FileSystem: webdav.Dir(dir),
+		OnComplete: func(string) error { return nil },
+	}
+
+	ctx := context.WithValue(context.Background(), uploadSizeKey{}, int64(-1))
+
+	// two uploads of the same name must not write to the same partial file
+	var files []webdav.File
+
+	for range 2 {
+		f, err := fs.OpenFile(ctx, "/scan.pdf", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files = append(files, f)
+	}
+
+	partial, err := filepath.Glob(filepath.Join(dir, ".scan.pdf-*"+partialUploadSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(partial) != 2 {
+		t.Errorf("want two partial files, got %v", partial)
+	}
+
+	for _, f := range files {
+		_, err := f.Write([]byte(testPDF))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUploadFSPassThrough(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	fs := uploadFS{
+		FileSystem: webdav.Dir(dir),
+		OnComplete: func(name string) error {
+			t.Errorf("unexpected OnComplete(%v)", name)
+
+			return nil
+		},
+	}
+
+	// LOCK on a new name creates an empty file, PROPPATCH opens the file
+	// for writing without truncating it
+	for _, flag := range []int{os.O_RDWR | os.O_CREATE | os.O_TRUNC, os.O_RDWR} {
+		f, err := fs.OpenFile(context.Background(), "/locked.pdf", flag, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "locked.pdf" {
+		t.Errorf("want only locked.pdf, got %v", entries)
+	}
+}